@@ -0,0 +1,133 @@
+// Hanabi-cli plays a game on a server from the terminal. It shows the game as a table, and
+// takes commands like "clue bob R", "play 2" and "discard 0".
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/egoon/hanabi-server/pkg/client"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+const help = `commands:
+  create <game> [variant]  create a game, e.g. "create go rainbow"
+  join <game> [token]      join a game, with the token to rejoin it
+  start                    start the game
+  clue <player> <clue>     clue a player with a color (B G R W Y) or a value (1-5)
+  play <card>              play the card with the index
+  discard <card>           discard the card with the index
+  leave                    leave the game, which ends it if it has started
+  show                     show the game again
+  help                     show the commands
+  quit                     leave`
+
+func main() {
+	addr := flag.String("addr", "localhost:579", "address of the server")
+	name := flag.String("name", "", "name of the player")
+	flag.Parse()
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "a name is needed, e.g. -name bob")
+		os.Exit(2)
+	}
+	// the log would be mixed with the game
+	log.SetLevel(log.ErrorLevel)
+
+	c, err := client.Dial(*addr, client.Options{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer c.Close()
+	go printEvents(c, model.PlayerID(*name))
+
+	fmt.Println(help)
+	input := bufio.NewScanner(os.Stdin)
+	for input.Scan() {
+		line := strings.TrimSpace(input.Text())
+		switch line {
+		case "quit", "exit":
+			return
+		case "help":
+			fmt.Println(help)
+		case "show":
+			render(os.Stdout, c.State(), model.PlayerID(*name))
+		default:
+			if err := run(c, model.PlayerID(*name), line); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+// run sends the action of a command to the server
+func run(c *client.Client, you model.PlayerID, line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	command, args := fields[0], fields[1:]
+	switch {
+	case command == "create" && (len(args) == 1 || len(args) == 2):
+		options := &model.GameOptions{}
+		if len(args) == 2 {
+			options.Variant = args[1]
+		}
+		return c.Create(model.GameID(args[0]), you, options)
+	case command == "join" && (len(args) == 1 || len(args) == 2):
+		token := ""
+		if len(args) == 2 {
+			token = args[1]
+		}
+		return c.Join(model.GameID(args[0]), you, token)
+	case command == "start" && len(args) == 0:
+		return c.Start()
+	case command == "leave" && len(args) == 0:
+		return c.Leave()
+	case command == "clue" && len(args) == 2:
+		return c.Clue(model.PlayerID(args[0]), strings.ToUpper(args[1]))
+	case (command == "play" || command == "discard") && len(args) == 1:
+		card, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("%s needs the index of a card, not %s", command, args[0])
+		}
+		if command == "play" {
+			return c.Play(card)
+		}
+		return c.Discard(card)
+	}
+	return fmt.Errorf("unknown command: %s. try help", line)
+}
+
+func printEvents(c *client.Client, you model.PlayerID) {
+	for event := range c.Events() {
+		switch {
+		case event.State != nil:
+			fmt.Println()
+			render(os.Stdout, *event.State, you)
+			if event.State.PlayedAction.Type == model.ActionCreate || event.State.PlayedAction.Type == model.ActionJoin && event.State.PlayedAction.ActivePlayer == you {
+				if session := c.Session(); session != nil {
+					fmt.Printf("Rejoin with: join %s %s\n", event.State.Id, session.Token)
+				}
+			}
+		case event.Error != nil:
+			fmt.Printf("error: %s\n", event.Error.Message)
+		case event.Chat != nil:
+			for _, message := range event.Chat.Messages {
+				fmt.Printf("%s: %s\n", message.Player, message.Message)
+			}
+		case event.Server != nil:
+			fmt.Printf("server: %s\n", event.Server.Event)
+		case event.Err != nil:
+			fmt.Println(event.Err)
+		}
+	}
+	fmt.Println("disconnected")
+	os.Exit(0)
+}
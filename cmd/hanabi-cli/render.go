@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// render writes the state as the player sees it: the counters, the last action, the table,
+// the discards and every hand. The player's own hand shows what the player has been told.
+func render(w io.Writer, state model.GameState, you model.PlayerID) {
+	options := state.Options.WithDefaults()
+	fmt.Fprintf(w, "Game %s  clues %d/%d  lives %d/%d  deck %d  score %d",
+		state.Id, state.Clues, options.MaxClues, state.Lives, options.MaxLives, state.Deck, len(state.Table))
+	if state.TurnsRemaining > 0 {
+		fmt.Fprintf(w, "  turns left %d", state.TurnsRemaining)
+	}
+	fmt.Fprintln(w)
+	if last := describe(state.PlayedAction); last != "" {
+		fmt.Fprintf(w, "Last: %s\n", last)
+	}
+
+	colors := model.VariantColors(options.Variant)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Table\t%s\n", strings.Join(stacks(state.Table, colors), "\t"))
+	fmt.Fprintf(tw, "Discards\t%s\n", strings.Join(discards(state.Discards, colors), "\t"))
+	_ = tw.Flush()
+
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if state.Started {
+		// the indexes of the cards, to play and discard by. The hands shrink once the deck is empty.
+		size := 0
+		for _, player := range state.Players {
+			if len(player.Cards) > size {
+				size = len(player.Cards)
+			}
+			if len(player.Knowledge) > size {
+				size = len(player.Knowledge)
+			}
+		}
+		indexes := make([]string, size)
+		for i := range indexes {
+			indexes[i] = fmt.Sprint(i)
+		}
+		fmt.Fprintf(tw, "\t%s\n", strings.Join(indexes, "\t"))
+	}
+	for i, player := range state.Players {
+		name := string(player.Id)
+		if player.Id == you {
+			name += " (you)"
+		}
+		if i == 0 && state.Started && !state.Ended {
+			name = "> " + name
+		} else {
+			name = "  " + name
+		}
+		size := len(player.Cards)
+		if len(player.Knowledge) > size {
+			size = len(player.Knowledge)
+		}
+		cards := make([]string, size)
+		for c := range cards {
+			knowledge := model.CardKnowledge{}
+			if c < len(player.Knowledge) {
+				knowledge = player.Knowledge[c]
+			}
+			if c >= len(player.Cards) {
+				cards[c] = known(knowledge)
+			} else {
+				cards[c] = player.Cards[c].Color + player.Cards[c].Value
+				if len(knowledge.Colors) > 0 || len(knowledge.Values) > 0 {
+					// the player knows something about the card
+					cards[c] += "*"
+				}
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, strings.Join(cards, "\t"))
+	}
+	_ = tw.Flush()
+	if state.Ended {
+		fmt.Fprintf(w, "Game over. Score %d\n", len(state.Table))
+	}
+}
+
+// stacks returns the highest card on the table of each color, or the color and "-"
+func stacks(table []model.Card, colors []string) []string {
+	highest := map[string]string{}
+	for _, card := range table {
+		if card.Value > highest[card.Color] {
+			highest[card.Color] = card.Value
+		}
+	}
+	stacks := make([]string, len(colors))
+	for i, color := range colors {
+		stacks[i] = color + "-"
+		if value, ok := highest[color]; ok {
+			stacks[i] = color + value
+		}
+	}
+	return stacks
+}
+
+// discards returns the discarded values of each color, in order, e.g. "R:1 1 4"
+func discards(cards []model.Card, colors []string) []string {
+	values := map[string][]string{}
+	for _, card := range cards {
+		values[card.Color] = append(values[card.Color], card.Value)
+	}
+	piles := make([]string, len(colors))
+	for i, color := range colors {
+		sort.Strings(values[color])
+		piles[i] = color + ":" + strings.Join(values[color], " ")
+	}
+	return piles
+}
+
+// known shows what a player has been told about one of their cards, e.g. "R?" for a red card
+func known(knowledge model.CardKnowledge) string {
+	color := "?"
+	if len(knowledge.Colors) == 1 {
+		color = knowledge.Colors[0]
+	} else if len(knowledge.Colors) > 1 {
+		// only a rainbow card is touched by two colors
+		color = model.ColorRainbow
+	}
+	value := "?"
+	if len(knowledge.Values) > 0 {
+		value = knowledge.Values[0]
+	}
+	return color + value
+}
+
+func describe(action model.Action) string {
+	switch action.Type {
+	case model.ActionCreate:
+		return fmt.Sprintf("%s created the game", action.ActivePlayer)
+	case model.ActionJoin:
+		return fmt.Sprintf("%s joined", action.ActivePlayer)
+	case model.ActionAddBot:
+		return fmt.Sprintf("%s added the bot %s", action.ActivePlayer, action.TargetPlayer)
+	case model.ActionStart:
+		return "the game started"
+	case model.ActionClue:
+		return fmt.Sprintf("%s clued %s with %s, touching cards %v", action.ActivePlayer, action.TargetPlayer, action.Clue, action.Card)
+	case model.ActionPlay:
+		return fmt.Sprintf("%s played card %d", action.ActivePlayer, action.Card[0])
+	case model.ActionDiscard:
+		return fmt.Sprintf("%s discarded card %d", action.ActivePlayer, action.Card[0])
+	case model.ActionTimeout:
+		return fmt.Sprintf("%s ran out of time", action.ActivePlayer)
+	case model.ActionLeave:
+		return fmt.Sprintf("%s left", action.ActivePlayer)
+	case model.ActionAbandon:
+		return "the game was abandoned"
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestRender(t *testing.T) {
+	state := model.GameState{
+		Id: "go",
+		Players: []model.Player{
+			{Id: "bob", Knowledge: []model.CardKnowledge{{Colors: []string{"R"}}, {Values: []string{"1"}, NotColors: []string{"B"}}, {Colors: []string{"B", "G"}}}},
+			{Id: "amy", Cards: []model.Card{{Color: "W", Value: "2"}, {Color: "R", Value: "5"}, {Color: "B", Value: "1"}}, Knowledge: []model.CardKnowledge{{}, {Values: []string{"5"}}, {}}},
+		},
+		Clues:        6,
+		Lives:        2,
+		Deck:         30,
+		Table:        []model.Card{{Color: "R", Value: "1"}, {Color: "R", Value: "2"}, {Color: "G", Value: "1"}},
+		Discards:     []model.Card{{Color: "Y", Value: "4"}, {Color: "Y", Value: "1"}, {Color: "B", Value: "3"}},
+		PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "amy", Card: []int{1}},
+		Started:      true,
+		Options:      model.GameOptions{HandSize: 3},
+	}
+	out := bytes.Buffer{}
+	render(&out, state, "bob")
+	assert.Equal(t, `Game go  clues 6/8  lives 2/3  deck 30  score 3
+Last: amy played card 1
+Table     B-   G1  R2  W-  Y-
+Discards  B:3  G:  R:  W:  Y:1 4
+             0   1    2
+> bob (you)  R?  ?1   M?
+  amy        W2  R5*  B1
+`, out.String())
+}
+
+func TestRender_FinalTurns(t *testing.T) {
+	state := model.GameState{
+		Id: "go",
+		Players: []model.Player{
+			{Id: "bob", Knowledge: []model.CardKnowledge{{}, {}}},
+			{Id: "amy", Cards: []model.Card{{Color: "W", Value: "2"}, {Color: "R", Value: "5"}, {Color: "B", Value: "1"}}, Knowledge: []model.CardKnowledge{{}, {}, {}}},
+		},
+		Clues:          8,
+		Lives:          3,
+		TurnsRemaining: 2,
+		Started:        true,
+		Options:        model.GameOptions{HandSize: 3},
+	}
+	out := bytes.Buffer{}
+	render(&out, state, "bob")
+	assert.Equal(t, "Game go  clues 8/8  lives 3/3  deck 0  score 0  turns left 2\n", strings.SplitAfter(out.String(), "\n")[0])
+	assert.Contains(t, out.String(), "> bob (you)  ??  ??\n", "bob's hand has shrunk")
+}
@@ -1,30 +1,98 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/egoon/hanabi-server/pkg/config"
+	"github.com/egoon/hanabi-server/pkg/io"
 	"github.com/egoon/hanabi-server/pkg/logic"
-	"github.com/egoon/hanabi-server/pkg/model"
+	"github.com/egoon/hanabi-server/pkg/server"
+	"github.com/egoon/hanabi-server/pkg/store"
 	log "github.com/sirupsen/logrus"
 )
 
 func main() {
-	ln, err := net.Listen("tcp", ":579")
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		return
+	}
 	if err != nil {
-		log.Error("Failed to start server: ", err, ". Exiting\n")
+		log.Fatal("Invalid configuration: ", err)
 	}
-	games := map[model.GameID]*model.Game{}
-	gameChan := make(chan *model.Game, 5)
-
-	// only HandleNewGames is allowed to add entries to the 'games' map
-	go logic.HandleNewGames(games, gameChan)
+	if cfg.Print {
+		out, _ := json.MarshalIndent(cfg, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	level, _ := log.ParseLevel(cfg.LogLevel)
+	log.SetLevel(level)
+	io.ReadTimeout = time.Duration(cfg.ReadTimeout)
 
-	for {
-		conn, err := ln.Accept()
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		log.Fatal("Failed to start server: ", err)
+	}
+	var wsLn net.Listener
+	if cfg.WebSocketAddr != "" {
+		wsLn, err = net.Listen("tcp", cfg.WebSocketAddr)
 		if err != nil {
-			log.Warn("waiting for connection failed: ", err)
-		} else {
-			go logic.HandleConnection(conn, games, gameChan)
+			log.Fatal("Failed to start websocket server: ", err)
 		}
 	}
+	games := logic.NewGameRegistry()
+	games.ActionBuffer = cfg.ActionBuffer
+	games.IdleTimeout = time.Duration(cfg.IdleTimeout)
+	if cfg.ExportDir != "" {
+		err = os.MkdirAll(cfg.ExportDir, 0755)
+		if err != nil {
+			log.Fatal("Failed to create replay directory: ", err)
+		}
+		games.ExportDir = cfg.ExportDir
+	}
+	if cfg.StoreDir != "" {
+		gameStore, err := store.NewFileStore(cfg.StoreDir)
+		if err != nil {
+			log.Fatal("Failed to open game store: ", err)
+		}
+		games.Store = gameStore
+		savedGames, err := gameStore.Load()
+		if err != nil {
+			log.Error("Failed to load saved games: ", err)
+		}
+		for _, saved := range savedGames {
+			err = logic.RestoreGame(saved, games)
+			if err != nil {
+				log.Warn("Failed to restore game ", saved.Id, ": ", err)
+			}
+		}
+	}
+
+	srv := server.New(games, time.Duration(cfg.GracePeriod))
+	if wsLn != nil {
+		// browser clients can't open raw sockets, so the same protocol is served over websockets
+		go func() {
+			err := srv.ServeWebSocket(wsLn)
+			if err != nil {
+				log.Fatal("WebSocket server stopped: ", err)
+			}
+		}()
+	}
+	go func() {
+		err := srv.Serve(ln)
+		if err != nil {
+			log.Fatal("Server stopped: ", err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	log.Info("Received ", <-signals, ". Shutting down")
+	srv.Shutdown()
 }
@@ -0,0 +1,82 @@
+// Simulate plays games between bot strategies in process, and reports how well they score.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/egoon/hanabi-server/pkg/bot"
+	"github.com/egoon/hanabi-server/pkg/logic"
+	"github.com/egoon/hanabi-server/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// strategies that can be named on the command line
+var strategies = map[string]func() bot.Strategy{
+	"cautious": func() bot.Strategy { return bot.Cautious{} },
+}
+
+func main() {
+	games := flag.Int("games", 1000, "number of games to play")
+	players := flag.Int("players", 3, "number of players in each game")
+	seed := flag.Int64("seed", 1, "seed of the deck of the first game. Each following game adds one")
+	variant := flag.String("variant", model.VariantStandard, "variant of the games, e.g. rainbow")
+	seats := flag.String("strategies", "cautious", "comma separated strategies, taking turns to fill the seats")
+	flag.Parse()
+
+	names := strings.Split(*seats, ",")
+	for _, name := range names {
+		if strategies[name] == nil {
+			log.Fatal("Unknown strategy: ", name)
+		}
+	}
+	// simulations only report the totals
+	log.SetLevel(log.WarnLevel)
+
+	if *players < 2 {
+		log.Fatal("A game needs at least 2 players")
+	}
+	options := model.GameOptions{Variant: *variant, MaxPlayers: *players}
+	scores := map[int]int{}
+	maxScore := 0
+	strikeouts := 0
+	totalScore := 0
+	totalTurns := 0
+	for i := 0; i < *games; i++ {
+		seatStrategies := make([]bot.Strategy, *players)
+		for s := range seatStrategies {
+			seatStrategies[s] = strategies[names[s%len(names)]]()
+		}
+		deck := model.CreateDeck(*variant, *seed+int64(i))
+		state, turns, err := logic.SimulateGame(deck, options, seatStrategies)
+		if err != nil {
+			log.Fatal("Game with seed ", *seed+int64(i), " failed: ", err)
+		}
+		score := len(state.Table)
+		scores[score]++
+		if score > maxScore {
+			maxScore = score
+		}
+		if state.Lives == 0 {
+			strikeouts++
+		}
+		totalScore += score
+		totalTurns += turns
+	}
+	if *games < 1 {
+		return
+	}
+
+	fmt.Printf("%d games with %d players of %s\n\n", *games, *players, *seats)
+	fmt.Println("score  games")
+	for score := 0; score <= maxScore; score++ {
+		if scores[score] > 0 {
+			fmt.Printf("%5d  %5d %s\n", score, scores[score], strings.Repeat("#", scores[score]*50 / *games))
+		}
+	}
+	fmt.Println()
+	fmt.Printf("average score: %.2f\n", float64(totalScore)/float64(*games))
+	fmt.Printf("strikeout rate: %.1f%%\n", 100*float64(strikeouts)/float64(*games))
+	fmt.Printf("average turns: %.1f\n", float64(totalTurns)/float64(*games))
+}
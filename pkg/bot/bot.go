@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bot plays a seat in a game. It is connected to the game like a player, and keeps the state the
+// player would see up to date with the updates it is sent. When it is its turn it asks its strategy
+// for an action, and submits it.
+type Bot struct {
+	id       model.PlayerID
+	strategy Strategy
+	submit   func(action *model.Action, state model.GameState) error
+
+	lock    sync.Mutex
+	pending []message
+	ready   chan struct{}
+	closed  bool
+}
+
+// New starts a bot playing as the given player. Submit is called with the action the bot takes,
+// and the state the bot based it on. The bot stops when it is closed.
+func New(id model.PlayerID, strategy Strategy, submit func(action *model.Action, state model.GameState) error) *Bot {
+	b := &Bot{
+		id:       id,
+		strategy: strategy,
+		submit:   submit,
+		ready:    make(chan struct{}, 1),
+	}
+	go b.run()
+	return b
+}
+
+// ReadAction always fails. A bot submits its actions itself.
+func (b *Bot) ReadAction() (*model.Action, error) {
+	return nil, fmt.Errorf("bot %s can not be read from", b.id)
+}
+
+// message is a state or an update that the bot has not seen yet
+type message struct {
+	update bool
+	bytes  []byte
+}
+
+// Write queues a state or an update for the bot. It never blocks the game, however slow the bot is.
+// Other messages, like chat, are ignored.
+func (b *Bot) Write(v interface{}) (int, error) {
+	_, update := v.(model.Update)
+	if _, ok := v.(model.GameState); !ok && !update {
+		return 0, nil
+	}
+	// the message shares slices with the state of the game, which keeps changing while the bot thinks
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return 0, fmt.Errorf("bot %s is closed", b.id)
+	}
+	b.pending = append(b.pending, message{update: update, bytes: bytes})
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+	return len(bytes), nil
+}
+
+func (b *Bot) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.ready)
+	}
+	return nil
+}
+
+func (b *Bot) run() {
+	// set from submitting an action until the bot sees it played, so it does not act twice on one turn
+	waiting := false
+	// seen is set once the bot has been sent a whole state, that the updates can be applied to
+	seen := false
+	state := model.GameState{}
+	for range b.ready {
+		b.lock.Lock()
+		pending := b.pending
+		b.pending = nil
+		b.lock.Unlock()
+		for _, msg := range pending {
+			if msg.update {
+				update := model.Update{}
+				if err := json.Unmarshal(msg.bytes, &update); err != nil || !seen {
+					continue
+				}
+				state.Apply(update)
+			} else {
+				state = model.GameState{}
+				if err := json.Unmarshal(msg.bytes, &state); err != nil {
+					continue
+				}
+				seen = true
+			}
+			if state.PlayedAction.IsTurn() && state.PlayedAction.ActivePlayer == b.id {
+				waiting = false
+			}
+			if waiting || !state.Started || state.Ended || len(state.Players) == 0 || state.Players[0].Id != b.id {
+				continue
+			}
+			action := b.strategy.NextAction(state)
+			action.ActivePlayer = b.id
+			err := b.submit(&action, state)
+			if err != nil {
+				log.Warn("bot ", b.id, " failed to ", action.Type, ": ", err)
+				continue
+			}
+			waiting = true
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+type strategyFunc func(state model.GameState) model.Action
+
+func (f strategyFunc) NextAction(state model.GameState) model.Action {
+	return f(state)
+}
+
+func TestBot(t *testing.T) {
+	submitted := make(chan *model.Action, 5)
+	discard := strategyFunc(func(state model.GameState) model.Action {
+		return model.Action{Type: model.ActionDiscard, Card: []int{0}}
+	})
+	b := New("bot", discard, func(action *model.Action, state model.GameState) error {
+		submitted <- action
+		return nil
+	})
+	turn := model.GameState{
+		Players: []model.Player{{Id: "bot"}, {Id: "Down"}},
+		Started: true,
+	}
+	notStarted := turn
+	notStarted.Started = false
+	notTurn := turn
+	notTurn.Players = []model.Player{{Id: "Down"}, {Id: "bot"}}
+
+	_, err := b.Write(notStarted)
+	assert.Nil(t, err)
+	_, err = b.Write(notTurn)
+	assert.Nil(t, err)
+	_, err = b.Write(turn)
+	assert.Nil(t, err)
+	// another state before the discard is played, e.g. a player that rejoined
+	_, err = b.Write(turn)
+	assert.Nil(t, err)
+	assert.Equal(t, &model.Action{Type: model.ActionDiscard, ActivePlayer: "bot", Card: []int{0}}, <-submitted)
+
+	turn.PlayedAction = model.Action{Type: model.ActionDiscard, ActivePlayer: "bot", Card: []int{0}}
+	_, err = b.Write(turn)
+	assert.Nil(t, err)
+	<-submitted
+
+	assert.Nil(t, b.Close())
+	assert.Nil(t, b.Close())
+	_, err = b.Write(turn)
+	assert.NotNil(t, err)
+	_, err = b.ReadAction()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(submitted), "the bot must not act twice on one turn")
+}
+
+func TestBot_Updates(t *testing.T) {
+	submitted := make(chan model.GameState, 5)
+	b := New("bot", strategyFunc(func(state model.GameState) model.Action {
+		return model.Action{Type: model.ActionDiscard, Card: []int{0}}
+	}), func(action *model.Action, state model.GameState) error {
+		submitted <- state
+		return nil
+	})
+	defer b.Close()
+	discard := model.Update{
+		Action:    model.Action{Type: model.ActionDiscard, ActivePlayer: "Down", Card: []int{0}},
+		Discarded: &model.Card{Color: "R", Value: "1"},
+		Drawn:     &model.Card{Color: "B", Value: "2"},
+		Clues:     8,
+		Deck:      9,
+		Started:   true,
+	}
+
+	// an update is of no use before the bot has a state to apply it to
+	_, err := b.Write(discard)
+	assert.Nil(t, err)
+	_, err = b.Write(model.GameState{
+		Players: []model.Player{{Id: "Down", Cards: []model.Card{{Color: "R", Value: "1"}}}, {Id: "bot"}},
+		Clues:   7,
+		Deck:    10,
+		Started: true,
+	})
+	assert.Nil(t, err)
+	_, err = b.Write(discard)
+	assert.Nil(t, err)
+	state := <-submitted
+	assert.Equal(t, []model.Player{{Id: "bot"}, {Id: "Down", Cards: []model.Card{{Color: "B", Value: "2"}}}}, state.Players)
+	assert.Equal(t, []model.Card{{Color: "R", Value: "1"}}, state.Discards)
+	assert.Equal(t, 8, state.Clues)
+	assert.Equal(t, 0, len(submitted))
+}
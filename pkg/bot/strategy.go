@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// Strategy decides what a bot does on its turn.
+type Strategy interface {
+	// NextAction is given the bot's own view of the game, on the bot's turn. It returns a clue, play or discard.
+	NextAction(state model.GameState) model.Action
+}
+
+var values = []string{"1", "2", "3", "4", "5"}
+
+// Cautious follows a few simple conventions. It only plays cards it knows are playable, and clues the
+// other players so that they know their playable cards. When there is nothing to play or clue, it
+// discards a card that no one has clued.
+type Cautious struct{}
+
+func (Cautious) NextAction(state model.GameState) model.Action {
+	knowledge := state.Players[0].Knowledge
+	for i, k := range knowledge {
+		if knowsPlayable(&state, k) {
+			return model.Action{Type: model.ActionPlay, Card: []int{i}}
+		}
+	}
+	if state.Clues > 0 {
+		if action, ok := playClue(&state); ok {
+			return action
+		}
+	}
+	if state.Clues > 0 && state.Clues == state.Options.WithDefaults().MaxClues {
+		// a discard would waste a clue
+		if action, ok := anyClue(&state); ok {
+			return action
+		}
+	}
+	return model.Action{Type: model.ActionDiscard, Card: []int{discardIndex(&state, knowledge)}}
+}
+
+// anyClue gives a value clue to the next player that has a card
+func anyClue(state *model.GameState) (model.Action, bool) {
+	for _, player := range state.Players[1:] {
+		for _, card := range player.Cards {
+			for _, value := range values {
+				if card.Value == value {
+					return model.Action{Type: model.ActionClue, TargetPlayer: player.Id, Clue: value, Card: []int{}}, true
+				}
+			}
+		}
+	}
+	return model.Action{}, false
+}
+
+// playClue finds a clue that tells another player about a card they can play. The players closest
+// in turn are clued first. A clue that makes the card known to be playable is preferred, but a clue
+// that only narrows the card down is given when there is none.
+func playClue(state *model.GameState) (model.Action, bool) {
+	best := model.Action{}
+	bestPossible := 0
+	for _, player := range state.Players[1:] {
+		for i, card := range player.Cards {
+			if !state.IsPlayable(card) || i >= len(player.Knowledge) || knowsPlayable(state, player.Knowledge[i]) {
+				continue
+			}
+			possible := len(possibleCards(player.Knowledge[i], state.Options.Variant))
+			for _, clue := range cluesTouching(card, state.Options.Variant) {
+				k := player.Knowledge[i]
+				k.AddClue(clue, true)
+				action := model.Action{Type: model.ActionClue, TargetPlayer: player.Id, Clue: clue, Card: []int{}}
+				if knowsPlayable(state, k) {
+					return action, true
+				}
+				after := len(possibleCards(k, state.Options.Variant))
+				if after < possible && (best.Type == "" || after < bestPossible) {
+					best = action
+					bestPossible = after
+				}
+			}
+		}
+	}
+	return best, best.Type != ""
+}
+
+func cluesTouching(card model.Card, variant string) []string {
+	clues := []string{card.Value}
+	for _, color := range model.VariantColors(variant) {
+		if card.IsTouchedBy(color) && color != model.ColorRainbow {
+			clues = append(clues, color)
+		}
+	}
+	return clues
+}
+
+// discardIndex picks a card that is known to be useless, or else a card that has not been clued
+func discardIndex(state *model.GameState, knowledge []model.CardKnowledge) int {
+	for i, k := range knowledge {
+		useless := true
+		for _, card := range possibleCards(k, state.Options.Variant) {
+			if !isPlayed(state, card) {
+				useless = false
+				break
+			}
+		}
+		if useless {
+			return i
+		}
+	}
+	for i, k := range knowledge {
+		if len(k.Colors) == 0 && len(k.Values) == 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// knowsPlayable returns true if every card the clues allow is playable
+func knowsPlayable(state *model.GameState, k model.CardKnowledge) bool {
+	cards := possibleCards(k, state.Options.Variant)
+	for _, card := range cards {
+		if !state.IsPlayable(card) {
+			return false
+		}
+	}
+	return len(cards) > 0
+}
+
+func possibleCards(k model.CardKnowledge, variant string) []model.Card {
+	var cards []model.Card
+	for _, color := range model.VariantColors(variant) {
+		for _, value := range values {
+			card := model.Card{Color: color, Value: value}
+			if k.Allows(card) {
+				cards = append(cards, card)
+			}
+		}
+	}
+	return cards
+}
+
+func isPlayed(state *model.GameState, card model.Card) bool {
+	for _, c := range state.Table {
+		if c == card {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+var (
+	r1 = model.Card{Color: "R", Value: "1"}
+	r2 = model.Card{Color: "R", Value: "2"}
+	b1 = model.Card{Color: "B", Value: "1"}
+	b2 = model.Card{Color: "B", Value: "2"}
+	b3 = model.Card{Color: "B", Value: "3"}
+	y4 = model.Card{Color: "Y", Value: "4"}
+)
+
+func TestCautious_NextAction(t *testing.T) {
+	testCases := []struct {
+		description string
+		state       model.GameState
+		expected    model.Action
+	}{
+		{
+			description: "Play card known to be playable",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {Values: []string{"2"}}, {Colors: []string{"R"}, Values: []string{"2"}}}},
+					{Id: "Down", Cards: []model.Card{b2, b3}, Knowledge: []model.CardKnowledge{{}, {}}},
+				},
+				Table: []model.Card{r1},
+				Clues: 8,
+			},
+			expected: model.Action{Type: model.ActionPlay, Card: []int{2}},
+		},
+		{
+			description: "Clue value that makes card known to be playable",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {}}},
+					{Id: "Down", Cards: []model.Card{b3, r1}, Knowledge: []model.CardKnowledge{{}, {}}},
+				},
+				Clues: 5,
+			},
+			expected: model.Action{Type: model.ActionClue, TargetPlayer: "Down", Clue: "1", Card: []int{}},
+		},
+		{
+			description: "Clue color that makes card known to be playable",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {}}},
+					{Id: "Down", Cards: []model.Card{b2, r2}, Knowledge: []model.CardKnowledge{{Values: []string{"2"}}, {Values: []string{"2"}}}},
+				},
+				Table: []model.Card{r1},
+				Clues: 5,
+			},
+			expected: model.Action{Type: model.ActionClue, TargetPlayer: "Down", Clue: "R", Card: []int{}},
+		},
+		{
+			description: "Clue playable card that takes two clues to know",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {}}},
+					{Id: "Down", Cards: []model.Card{b3, r2}, Knowledge: []model.CardKnowledge{{}, {}}},
+				},
+				Table: []model.Card{r1},
+				Clues: 5,
+			},
+			expected: model.Action{Type: model.ActionClue, TargetPlayer: "Down", Clue: "2", Card: []int{}},
+		},
+		{
+			description: "Don't clue card that is already known to be playable",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {Values: []string{"4"}}}},
+					{Id: "Down", Cards: []model.Card{r1, b3}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}}, {}}},
+				},
+				Clues: 5,
+			},
+			expected: model.Action{Type: model.ActionDiscard, Card: []int{0}},
+		},
+		{
+			description: "Discard card known to be played",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {Colors: []string{"B"}, Values: []string{"1"}}}},
+					{Id: "Down", Cards: []model.Card{y4, b3}, Knowledge: []model.CardKnowledge{{}, {}}},
+				},
+				Table: []model.Card{b1},
+				Clues: 5,
+			},
+			expected: model.Action{Type: model.ActionDiscard, Card: []int{1}},
+		},
+		{
+			description: "Discard card that is not clued",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{Values: []string{"4"}}, {NotValues: []string{"4"}}}},
+					{Id: "Down", Cards: []model.Card{y4, b3}, Knowledge: []model.CardKnowledge{{}, {}}},
+				},
+				Clues: 0,
+			},
+			expected: model.Action{Type: model.ActionDiscard, Card: []int{1}},
+		},
+		{
+			description: "Clue instead of discarding at max clues",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {}}},
+					{Id: "Down", Cards: []model.Card{y4, b3}, Knowledge: []model.CardKnowledge{{}, {}}},
+				},
+				Clues:   4,
+				Options: model.GameOptions{MaxClues: 4},
+			},
+			expected: model.Action{Type: model.ActionClue, TargetPlayer: "Down", Clue: "4", Card: []int{}},
+		},
+		{
+			description: "Clue rainbow card with color of other playable suit",
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "bot", Knowledge: []model.CardKnowledge{{}, {}}},
+					{Id: "Down", Cards: []model.Card{{Color: model.ColorRainbow, Value: "2"}}, Knowledge: []model.CardKnowledge{{Values: []string{"2"}}}},
+				},
+				Table:   []model.Card{r1, {Color: model.ColorRainbow, Value: "1"}},
+				Clues:   5,
+				Options: model.GameOptions{Variant: model.VariantRainbow},
+			},
+			// R2 and M2 are both playable, while a blue clue leaves B2
+			expected: model.Action{Type: model.ActionClue, TargetPlayer: "Down", Clue: "R", Card: []int{}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Cautious{}.NextAction(tc.state))
+		})
+	}
+}
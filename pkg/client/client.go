@@ -0,0 +1,384 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/egoon/hanabi-server/pkg/io"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+const (
+	defaultPingInterval   = 10 * time.Second
+	defaultReconnects     = 5
+	defaultReconnectDelay = time.Second
+	// the number of events that are kept until they are read
+	eventBuffer = 100
+	// the largest message the client reads, which is a state of a full game
+	maxMessageSize = 1 << 20
+)
+
+// Options tune a client. A field that is not set gets its default.
+type Options struct {
+	// PingInterval is how often the client pings in a game, to stay connected. It must be shorter
+	// than the read timeout of the server.
+	PingInterval time.Duration
+	// Reconnects is how many times the client tries to rejoin a game it lost the connection to.
+	// A negative number turns reconnecting off.
+	Reconnects int
+	// ReconnectDelay is the time before each try to reconnect
+	ReconnectDelay time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.PingInterval <= 0 {
+		o.PingInterval = defaultPingInterval
+	}
+	if o.Reconnects == 0 {
+		o.Reconnects = defaultReconnects
+	}
+	if o.ReconnectDelay <= 0 {
+		o.ReconnectDelay = defaultReconnectDelay
+	}
+	return o
+}
+
+// Event is a message from the server, or the loss of the connection. Exactly one field is set.
+type Event struct {
+	// State is the view of the game after a state or an update from the server
+	State  *model.GameState
+	Error  *model.Error
+	Chat   *model.Chat
+	Server *model.ServerEvent
+	// Err is set when the connection is lost and could not be reconnected. It is the last event.
+	Err error
+}
+
+// Client plays a seat in a game, over a connection that sends newline delimited JSON. It keeps
+// its view of the game up to date with the updates from the server, pings to stay connected,
+// and rejoins the game with its session if the connection is lost.
+type Client struct {
+	addr    string
+	options Options
+	events  chan Event
+	done    chan struct{}
+
+	mu      sync.Mutex
+	conn    net.Conn
+	gameID  model.GameID
+	session *model.Session
+	state   model.GameState
+	// resyncing is set from when a gap in the updates is found until it is filled
+	resyncing bool
+	closed    bool
+}
+
+// Dial connects to a server
+func Dial(addr string, options Options) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	c := &Client{
+		addr:    addr,
+		options: options.withDefaults(),
+		events:  make(chan Event, eventBuffer),
+		done:    make(chan struct{}),
+		conn:    conn,
+	}
+	go c.read(conn)
+	go c.ping()
+	return c, nil
+}
+
+// Events returns the events from the server, in the order they were sent. The answers to the pings
+// of the client are not sent as events. The channel is closed when the client is closed, or the
+// connection is lost for good, and must be read for the client to go on.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// State returns the latest view of the game
+func (c *Client) State() model.GameState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Copy()
+}
+
+// Session returns the session of the player, once the server has let the client into a game
+func (c *Client) Session() *model.Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// Create creates a game and takes its first seat. Options may be nil for the default options.
+func (c *Client) Create(gameID model.GameID, player model.PlayerID, options *model.GameOptions) error {
+	return c.send(model.Action{Type: model.ActionCreate, GameID: gameID, ActivePlayer: player, Options: options})
+}
+
+// Join takes a seat in a game. The token is only needed to rejoin as a player that is already in the game.
+func (c *Client) Join(gameID model.GameID, player model.PlayerID, token string) error {
+	return c.send(model.Action{Type: model.ActionJoin, GameID: gameID, ActivePlayer: player, Token: token})
+}
+
+func (c *Client) Start() error {
+	return c.send(model.Action{Type: model.ActionStart})
+}
+
+// Clue tells a player which of their cards have a color or a value
+func (c *Client) Clue(player model.PlayerID, clue string) error {
+	return c.send(model.Action{Type: model.ActionClue, TargetPlayer: player, Clue: clue})
+}
+
+// Play plays the card with the given index in the player's hand
+func (c *Client) Play(card int) error {
+	return c.send(model.Action{Type: model.ActionPlay, Card: []int{card}})
+}
+
+// Discard discards the card with the given index in the player's hand
+func (c *Client) Discard(card int) error {
+	return c.send(model.Action{Type: model.ActionDiscard, Card: []int{card}})
+}
+
+// Leave gives up the seat in the game, or ends the game if it has started. The client may then
+// create or join another game.
+func (c *Client) Leave() error {
+	err := c.send(model.Action{Type: model.ActionLeave})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = nil
+	c.gameID = ""
+	c.state = model.GameState{}
+	c.resyncing = false
+	return nil
+}
+
+// Close closes the connection, and the events
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	return c.conn.Close()
+}
+
+func (c *Client) send(action model.Action) error {
+	msg, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return fmt.Errorf("client is closed")
+	}
+	_, err = c.conn.Write(append(msg, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", action.Type, err)
+	}
+	return nil
+}
+
+func (c *Client) emit(event Event) {
+	select {
+	case c.events <- event:
+	case <-c.done:
+	}
+}
+
+func (c *Client) ping() {
+	ticker := time.NewTicker(c.options.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		// the server only answers pings from players in a game
+		if c.Session() == nil {
+			continue
+		}
+		err := c.send(model.Action{Type: model.ActionPing})
+		if err != nil {
+			log.Debug("ping failed: ", err)
+		}
+	}
+}
+
+func (c *Client) read(conn net.Conn) {
+	defer close(c.events)
+	for {
+		err := c.readMessages(conn)
+		conn = c.reconnect(err)
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// readMessages reads from the connection until it fails
+func (c *Client) readMessages(conn net.Conn) error {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxMessageSize)
+	for scanner.Scan() {
+		envelope, err := io.Decode(scanner.Bytes())
+		if err != nil {
+			log.Warn("failed to read message from server: ", err)
+			continue
+		}
+		err = c.handle(envelope)
+		if err != nil {
+			log.Warn("failed to read ", envelope.Type, " from server: ", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection closed by server")
+}
+
+func (c *Client) handle(envelope model.Envelope) error {
+	switch envelope.Type {
+	case model.MessageState:
+		state := model.GameState{}
+		if err := json.Unmarshal(envelope.Payload, &state); err != nil {
+			return err
+		}
+		c.handleState(state)
+	case model.MessageUpdate:
+		update := model.Update{}
+		if err := json.Unmarshal(envelope.Payload, &update); err != nil {
+			return err
+		}
+		c.handleUpdate(update)
+	case model.MessageError:
+		modelErr := &model.Error{}
+		if err := json.Unmarshal(envelope.Payload, modelErr); err != nil {
+			return err
+		}
+		c.emit(Event{Error: modelErr})
+	case model.MessageChat:
+		chat := &model.Chat{}
+		if err := json.Unmarshal(envelope.Payload, chat); err != nil {
+			return err
+		}
+		c.emit(Event{Chat: chat})
+	case model.MessageEvent:
+		event := &model.ServerEvent{}
+		if err := json.Unmarshal(envelope.Payload, event); err != nil {
+			return err
+		}
+		c.emit(Event{Server: event})
+	}
+	return nil
+}
+
+func (c *Client) handleState(state model.GameState) {
+	c.mu.Lock()
+	if state.Session != nil {
+		// the reply to a create or join, which comes before the state of the game
+		c.session = state.Session
+		c.gameID = state.Id
+		c.mu.Unlock()
+		return
+	}
+	c.state = state
+	c.resyncing = false
+	view := c.state.Copy()
+	c.mu.Unlock()
+	if state.PlayedAction.Type != model.ActionPing {
+		c.emit(Event{State: &view})
+	}
+}
+
+func (c *Client) handleUpdate(update model.Update) {
+	c.mu.Lock()
+	switch {
+	case c.session == nil:
+		// sent before the client left the game
+		c.mu.Unlock()
+		return
+	case update.Seq <= c.state.Seq:
+		// already applied, e.g. sent again after a resync
+		c.mu.Unlock()
+		return
+	case update.Seq > c.state.Seq+1:
+		since := c.state.Seq
+		resync := !c.resyncing
+		c.resyncing = true
+		c.mu.Unlock()
+		if resync {
+			log.Info("missed updates after ", since, ". resyncing")
+			if err := c.send(model.Action{Type: model.ActionResync, Since: since}); err != nil {
+				log.Warn("resync failed: ", err)
+			}
+		}
+		return
+	}
+	c.state.Apply(update)
+	c.resyncing = false
+	view := c.state.Copy()
+	c.mu.Unlock()
+	c.emit(Event{State: &view})
+}
+
+// reconnect rejoins the game after the connection failed, and returns the new connection.
+// It returns nil if the client was closed, or could not rejoin.
+func (c *Client) reconnect(cause error) net.Conn {
+	c.mu.Lock()
+	closed := c.closed
+	session := c.session
+	gameID := c.gameID
+	ended := c.state.Ended
+	c.mu.Unlock()
+	if closed || ended {
+		return nil
+	}
+	if session == nil || c.options.Reconnects < 0 {
+		c.emit(Event{Err: fmt.Errorf("connection lost: %w", cause)})
+		return nil
+	}
+	for i := 0; i < c.options.Reconnects; i++ {
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(c.options.ReconnectDelay):
+		}
+		conn, err := net.Dial("tcp", c.addr)
+		if err != nil {
+			log.Info("failed to reconnect to ", c.addr, ": ", err)
+			continue
+		}
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		c.conn = conn
+		c.mu.Unlock()
+		err = c.send(model.Action{Type: model.ActionJoin, GameID: gameID, ActivePlayer: session.Player, Token: session.Token})
+		if err != nil {
+			log.Info("failed to rejoin game ", gameID, ": ", err)
+			_ = conn.Close()
+			continue
+		}
+		return conn
+	}
+	c.emit(Event{Err: fmt.Errorf("connection lost: %w", cause)})
+	return nil
+}
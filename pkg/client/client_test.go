@@ -0,0 +1,188 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/io"
+	"github.com/egoon/hanabi-server/pkg/logic"
+	"github.com/egoon/hanabi-server/pkg/model"
+	"github.com/egoon/hanabi-server/pkg/server"
+)
+
+// the clients ping well within the read timeout of the server
+var testOptions = Options{PingInterval: 100 * time.Millisecond, ReconnectDelay: 10 * time.Millisecond}
+
+func TestMain(m *testing.M) {
+	// set before any server reads from a connection
+	io.ReadTimeout = time.Second
+	os.Exit(m.Run())
+}
+
+func startServer(t *testing.T) string {
+	srv := server.New(logic.NewGameRegistry(), 0)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		assert.Nil(t, srv.Serve(ln))
+	}()
+	t.Cleanup(srv.Shutdown)
+	return ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *Client {
+	c, err := Dial(addr, testOptions)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func nextEvent(t *testing.T, c *Client) Event {
+	select {
+	case event, ok := <-c.Events():
+		if !ok {
+			t.Fatal("the events were closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event from the server")
+	}
+	return Event{}
+}
+
+func nextState(t *testing.T, c *Client) model.GameState {
+	event := nextEvent(t, c)
+	if event.State == nil {
+		t.Fatalf("expected a state, got %+v", event)
+	}
+	return *event.State
+}
+
+// startGame lets two clients start a game, where Up has the first turn
+func startGame(t *testing.T, addr string) (*Client, *Client) {
+	up := dial(t, addr)
+	assert.Nil(t, up.Create("game", "Up", &model.GameOptions{MaxLives: 1}))
+	assert.Equal(t, model.ActionCreate, nextState(t, up).PlayedAction.Type)
+	assert.Equal(t, &model.Session{Player: "Up", Token: up.Session().Token}, up.Session())
+
+	down := dial(t, addr)
+	assert.Nil(t, down.Join("game", "Down", ""))
+	assert.Equal(t, model.ActionJoin, nextState(t, down).PlayedAction.Type)
+	assert.Equal(t, 2, len(nextState(t, up).Players))
+
+	assert.Nil(t, up.Start())
+	assert.True(t, nextState(t, up).Started)
+	assert.True(t, nextState(t, down).Started)
+	return up, down
+}
+
+func TestClient_Game(t *testing.T) {
+	up, down := startGame(t, startServer(t))
+
+	assert.Nil(t, down.Play(0))
+	assert.Equal(t, model.ErrNotYourTurn, nextEvent(t, down).Error.Code)
+
+	assert.Nil(t, up.Clue("Down", "1"))
+	upView := nextState(t, up)
+	downView := nextState(t, down)
+	assert.Equal(t, upView.Seq, downView.Seq)
+	assert.Equal(t, model.PlayerID("Down"), downView.Players[0].Id)
+	assert.Nil(t, downView.Players[0].Cards, "the player does not see their own cards")
+	assert.Equal(t, upView.Players[0].Knowledge, downView.Players[0].Knowledge)
+	unplayable := -1
+	for i, card := range upView.Players[0].Cards {
+		if card.Value != "1" {
+			unplayable = i
+		}
+	}
+	assert.NotEqual(t, -1, unplayable)
+
+	// a single mistake ends the game
+	assert.Nil(t, down.Play(unplayable))
+	ended := nextState(t, down)
+	assert.True(t, ended.Ended)
+	assert.Equal(t, ended.Discards, nextState(t, up).Discards)
+	assert.Equal(t, ended, down.State())
+	_, ok := <-down.Events()
+	assert.False(t, ok, "the client does not reconnect to an ended game")
+}
+
+func TestClient_Leave(t *testing.T) {
+	addr := startServer(t)
+	up := dial(t, addr)
+	assert.Nil(t, up.Create("game", "Up", nil))
+	nextState(t, up)
+	down := dial(t, addr)
+	assert.Nil(t, down.Join("game", "Down", ""))
+	nextState(t, down)
+	nextState(t, up)
+
+	assert.Nil(t, down.Leave())
+	left := nextState(t, up)
+	assert.Equal(t, model.ActionLeave, left.PlayedAction.Type)
+	assert.Equal(t, []model.Player{{Id: "Up"}}, left.Players)
+	assert.Nil(t, down.Session())
+	assert.Equal(t, model.GameState{}, down.State())
+
+	// the client that left may create another game
+	assert.Nil(t, down.Create("other", "Down", nil))
+	assert.Equal(t, model.GameID("other"), nextState(t, down).Id)
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	up, down := startGame(t, startServer(t))
+
+	// the connection breaks, and Down rejoins with its session
+	down.mu.Lock()
+	_ = down.conn.Close()
+	down.mu.Unlock()
+	rejoined := nextState(t, down)
+	assert.Equal(t, model.ActionJoin, rejoined.PlayedAction.Type)
+	assert.True(t, rejoined.Started)
+	assert.Equal(t, model.ActionJoin, nextState(t, up).PlayedAction.Type)
+
+	// the clients are idle for longer than the read timeout, but ping to stay connected
+	time.Sleep(2 * io.ReadTimeout)
+	assert.Nil(t, up.Clue("Down", "R"))
+	assert.Equal(t, model.ActionClue, nextState(t, up).PlayedAction.Type)
+	assert.Equal(t, model.ActionClue, nextState(t, down).PlayedAction.Type)
+	assert.Nil(t, down.Discard(0))
+	assert.Equal(t, model.ActionDiscard, nextState(t, up).PlayedAction.Type)
+	assert.Equal(t, model.ActionDiscard, nextState(t, down).PlayedAction.Type)
+}
+
+func TestClient_Resync(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	c := &Client{conn: clientConn, events: make(chan Event, 10), done: make(chan struct{})}
+	c.session = &model.Session{Player: "Up"}
+	c.state = model.GameState{Seq: 3}
+	sent := make(chan model.Action, 1)
+	go func() {
+		scanner := bufio.NewScanner(serverConn)
+		for scanner.Scan() {
+			action := model.Action{}
+			assert.Nil(t, json.Unmarshal(scanner.Bytes(), &action))
+			sent <- action
+		}
+	}()
+
+	c.handleUpdate(model.Update{Seq: 5, Action: model.Action{Type: model.ActionPing}})
+	assert.Equal(t, model.Action{Type: model.ActionResync, Since: 3}, <-sent)
+	c.handleUpdate(model.Update{Seq: 6, Action: model.Action{Type: model.ActionPing}})
+	assert.Equal(t, 0, len(sent), "a gap is only resynced once")
+	assert.Equal(t, 0, len(c.events))
+
+	c.handleUpdate(model.Update{Seq: 4, Action: model.Action{Type: model.ActionPing}, Clues: 7})
+	assert.Equal(t, int64(4), c.State().Seq)
+	assert.Equal(t, 7, (<-c.events).State.Clues)
+	c.handleUpdate(model.Update{Seq: 4, Action: model.Action{Type: model.ActionPing}})
+	assert.Equal(t, 0, len(c.events), "updates that were already applied are ignored")
+	assert.Nil(t, c.Close())
+	_ = serverConn.Close()
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// environment variables are named by their flag, e.g. HANABI_READ_TIMEOUT for -read-timeout
+const envPrefix = "HANABI_"
+
+// Config is the configuration of the server. Flags override environment variables, which
+// override the config file, which overrides the defaults.
+type Config struct {
+	// Addr is where clients connect with newline delimited JSON
+	Addr string `json:"addr"`
+	// WebSocketAddr is where browser clients connect with websockets. Empty means no websockets.
+	WebSocketAddr string `json:"wsAddr"`
+	// StoreDir keeps unfinished games, to restore them when the server starts. Empty means games are not kept.
+	StoreDir string `json:"storeDir"`
+	// ExportDir is where ended games are written as hanab.live replays. Empty means games are not exported.
+	ExportDir string `json:"exportDir"`
+	// ReadTimeout closes connections that send nothing for this long
+	ReadTimeout Duration `json:"readTimeout"`
+	// GracePeriod is how long running games may go on after the server is told to stop
+	GracePeriod Duration `json:"grace"`
+	// IdleTimeout abandons games that no player or spectator has been connected to for this long. Zero means never.
+	IdleTimeout Duration `json:"idleTimeout"`
+	// ActionBuffer is the number of actions that may be queued for each game
+	ActionBuffer int    `json:"actionBuffer"`
+	LogLevel     string `json:"logLevel"`
+
+	// File is the config file that was read, if any
+	File string `json:"-"`
+	// Print asks for the configuration to be printed instead of starting the server
+	Print bool `json:"-"`
+}
+
+func Default() Config {
+	return Config{
+		Addr:          ":579",
+		WebSocketAddr: ":580",
+		StoreDir:      "games",
+		ExportDir:     "replays",
+		ReadTimeout:   Duration(30 * time.Second),
+		GracePeriod:   Duration(30 * time.Second),
+		IdleTimeout:   Duration(10 * time.Minute),
+		ActionBuffer:  5,
+		LogLevel:      log.InfoLevel.String(),
+	}
+}
+
+// Load reads the configuration from the command line arguments, without the program name,
+// the environment and the config file. getenv is usually os.Getenv.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	// the flags are parsed twice: first to find the config file, and then to override it
+	found := Default()
+	err := found.flagSet().Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+	path := found.File
+	if path == "" {
+		path = getenv(envName("config"))
+	}
+
+	c := Default()
+	if path != "" {
+		err = c.readFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	flags := c.flagSet()
+	flags.VisitAll(func(f *flag.Flag) {
+		value := getenv(envName(f.Name))
+		if err != nil || value == "" || f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, envName(f.Name), setErr)
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	_ = flags.Parse(args)
+	c.File = path
+	return c, c.validate()
+}
+
+func (c *Config) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.StringVar(&c.File, "config", c.File, "JSON config file")
+	flags.BoolVar(&c.Print, "print-config", c.Print, "print the configuration and exit")
+	flags.StringVar(&c.Addr, "addr", c.Addr, "address of newline delimited JSON clients")
+	flags.StringVar(&c.WebSocketAddr, "ws-addr", c.WebSocketAddr, "address of websocket clients. Empty disables websockets")
+	flags.StringVar(&c.StoreDir, "store-dir", c.StoreDir, "directory of unfinished games. Empty disables restoring games")
+	flags.StringVar(&c.ExportDir, "export-dir", c.ExportDir, "directory of hanab.live replays. Empty disables replays")
+	flags.Var(&c.ReadTimeout, "read-timeout", "close connections that send nothing for this long")
+	flags.Var(&c.GracePeriod, "grace", "how long running games may go on after the server is told to stop")
+	flags.Var(&c.IdleTimeout, "idle-timeout", "abandon games that no one has been connected to for this long. 0 never abandons games")
+	flags.IntVar(&c.ActionBuffer, "action-buffer", c.ActionBuffer, "number of actions that may be queued for each game")
+	flags.StringVar(&c.LogLevel, "log-level", c.LogLevel, "one of panic, fatal, error, warn, info, debug and trace")
+	return flags
+}
+
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(c)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("addr must be set")
+	}
+	if c.ReadTimeout <= 0 {
+		return fmt.Errorf("read timeout must be positive")
+	}
+	if c.GracePeriod < 0 {
+		return fmt.Errorf("grace period may not be negative")
+	}
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("idle timeout may not be negative")
+	}
+	if c.IdleTimeout > 0 && c.IdleTimeout < Duration(time.Second) {
+		return fmt.Errorf("idle timeout must be 0 or at least 1s")
+	}
+	if c.ActionBuffer < 1 {
+		return fmt.Errorf("action buffer must be at least 1")
+	}
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("unknown log level: %s", c.LogLevel)
+	}
+	return nil
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Duration is a time.Duration that is written as e.g. "30s" in config files
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses the duration of a flag or environment variable
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	return d.Set(value)
+}
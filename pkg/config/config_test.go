@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hanabi-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "server.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"addr": ":1579", "readTimeout": "1m", "logLevel": "debug"}`), 0644))
+	typo := filepath.Join(dir, "typo.json")
+	assert.Nil(t, ioutil.WriteFile(typo, []byte(`{"adress": ":1579"}`), 0644))
+
+	withFile := Default()
+	withFile.File = file
+	withFile.Addr = ":1579"
+	withFile.ReadTimeout = Duration(time.Minute)
+	withFile.LogLevel = "debug"
+
+	testCases := []struct {
+		description string
+		args        []string
+		env         map[string]string
+		expected    Config
+		expectedErr error
+	}{
+		{
+			description: "Defaults",
+			expected:    Default(),
+		},
+		{
+			description: "Config file from flag",
+			args:        []string{"-config", file},
+			expected:    withFile,
+		},
+		{
+			description: "Config file from environment",
+			env:         map[string]string{"HANABI_CONFIG": file},
+			expected:    withFile,
+		},
+		{
+			description: "Environment overrides config file",
+			args:        []string{"-config", file},
+			env:         map[string]string{"HANABI_ADDR": ":2579", "HANABI_ACTION_BUFFER": "10"},
+			expected: func() Config {
+				c := withFile
+				c.Addr = ":2579"
+				c.ActionBuffer = 10
+				return c
+			}(),
+		},
+		{
+			description: "Flag overrides environment",
+			args:        []string{"-addr", ":3579", "-ws-addr", "", "-grace", "1s", "-print-config"},
+			env:         map[string]string{"HANABI_ADDR": ":2579", "HANABI_PRINT_CONFIG": "true"},
+			expected: func() Config {
+				c := Default()
+				c.Addr = ":3579"
+				c.WebSocketAddr = ""
+				c.GracePeriod = Duration(time.Second)
+				c.Print = true
+				return c
+			}(),
+		},
+		{
+			description: "Idle timeout off",
+			env:         map[string]string{"HANABI_IDLE_TIMEOUT": "0s"},
+			expected: func() Config {
+				c := Default()
+				c.IdleTimeout = 0
+				return c
+			}(),
+		},
+		{
+			description: "Invalid environment variable",
+			env:         map[string]string{"HANABI_READ_TIMEOUT": "soon"},
+			expectedErr: fmt.Errorf(`invalid value "soon" for HANABI_READ_TIMEOUT: time: invalid duration "soon"`),
+		},
+		{
+			description: "Unknown field in config file",
+			args:        []string{"-config", typo},
+			expectedErr: fmt.Errorf(`failed to read config file %s: json: unknown field "adress"`, typo),
+		},
+		{
+			description: "Invalid action buffer",
+			args:        []string{"-action-buffer", "0"},
+			expectedErr: fmt.Errorf("action buffer must be at least 1"),
+		},
+		{
+			description: "Invalid log level",
+			env:         map[string]string{"HANABI_LOG_LEVEL": "loud"},
+			expectedErr: fmt.Errorf("unknown log level: loud"),
+		},
+		{
+			description: "Negative read timeout",
+			args:        []string{"-read-timeout", "-1s"},
+			expectedErr: fmt.Errorf("read timeout must be positive"),
+		},
+		{
+			description: "Negative idle timeout",
+			args:        []string{"-idle-timeout", "-1m"},
+			expectedErr: fmt.Errorf("idle timeout may not be negative"),
+		},
+		{
+			description: "Short idle timeout",
+			args:        []string{"-idle-timeout", "3ns"},
+			expectedErr: fmt.Errorf("idle timeout must be 0 or at least 1s"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config, err := Load(tc.args, func(name string) string { return tc.env[name] })
+			if tc.expectedErr != nil {
+				assert.NotNil(t, err)
+				if err != nil {
+					assert.Equal(t, tc.expectedErr.Error(), err.Error())
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, config)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// hanab.live action types
+const (
+	hanabLivePlay      = 0
+	hanabLiveDiscard   = 1
+	hanabLiveColorClue = 2
+	hanabLiveRankClue  = 3
+	hanabLiveGameOver  = 4
+)
+
+// hanab.live end conditions of games that did not end by the rules
+const (
+	// a player ran out of time
+	hanabLiveTimeout = 3
+	// a player left the game
+	hanabLiveTerminated = 4
+	// no one was connected to the game
+	hanabLiveIdleTimeout = 6
+)
+
+// hanab.live has no white suit in its standard variants, so white is exported as purple.
+// The suit and clue color indexes are the same in both supported variants.
+var hanabLiveSuits = map[string]int{
+	"R":                0, // red
+	"Y":                1, // yellow
+	"G":                2, // green
+	"B":                3, // blue
+	"W":                4, // purple
+	model.ColorRainbow: 5, // rainbow
+}
+
+var hanabLiveVariants = map[string]string{
+	model.VariantStandard: "No Variant",
+	model.VariantRainbow:  "Rainbow (6 Suits)",
+}
+
+// HanabLiveReplay is a game in the JSON format that hanab.live imports as a replay
+type HanabLiveReplay struct {
+	Players []model.PlayerID  `json:"players"`
+	Deck    []HanabLiveCard   `json:"deck"`
+	Actions []HanabLiveAction `json:"actions"`
+	Options HanabLiveOptions  `json:"options"`
+}
+
+type HanabLiveCard struct {
+	SuitIndex int `json:"suitIndex"`
+	Rank      int `json:"rank"`
+}
+
+// HanabLiveAction targets a card order for plays and discards, and a player index for clues
+type HanabLiveAction struct {
+	Type   int `json:"type"`
+	Target int `json:"target"`
+	Value  int `json:"value"`
+}
+
+type HanabLiveOptions struct {
+	Variant      string `json:"variant"`
+	OneExtraCard bool   `json:"oneExtraCard,omitempty"`
+	OneLessCard  bool   `json:"oneLessCard,omitempty"`
+	EmptyClues   bool   `json:"emptyClues,omitempty"`
+}
+
+// NewHanabLiveReplay converts a finished game. Games with house rules that hanab.live
+// can't express are rejected.
+func NewHanabLiveReplay(record model.GameRecord) (*HanabLiveReplay, error) {
+	options, err := hanabLiveOptions(record.Options, len(record.Players))
+	if err != nil {
+		return nil, err
+	}
+	replay := &HanabLiveReplay{
+		Players: record.Players,
+		Deck:    make([]HanabLiveCard, len(record.Deck)),
+		Actions: make([]HanabLiveAction, 0, len(record.Actions)),
+		Options: options,
+	}
+	for i, card := range record.Deck {
+		replay.Deck[i], err = hanabLiveCard(card)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// hanab.live identifies cards by their position in the deck, so track the position of every card in hand
+	seats := make(map[model.PlayerID]int, len(record.Players))
+	for i, player := range record.Players {
+		seats[player] = i
+	}
+	cardsPerPlayer := record.Options.CardsPerPlayer(len(record.Players))
+	hands := make([][]int, len(record.Players))
+	nextCard := 0
+	for i := range hands {
+		hands[i] = make([]int, cardsPerPlayer)
+		for j := range hands[i] {
+			hands[i][j] = nextCard
+			nextCard++
+		}
+	}
+	for _, action := range record.Actions {
+		switch action.Type {
+		case model.ActionPlay, model.ActionDiscard:
+			seat := seats[action.ActivePlayer]
+			hand := hands[seat]
+			index := action.Card[0]
+			if index >= len(hand) {
+				return nil, fmt.Errorf("%s of a card that is not in the hand of %s", action.Type, action.ActivePlayer)
+			}
+			order := hand[index]
+			actionType := hanabLivePlay
+			if action.Type == model.ActionDiscard {
+				actionType = hanabLiveDiscard
+			}
+			replay.Actions = append(replay.Actions, HanabLiveAction{Type: actionType, Target: order})
+			if nextCard < len(record.Deck) {
+				hand[index] = nextCard
+				nextCard++
+			} else {
+				// the deck is empty, and the hand shrinks
+				hands[seat] = append(hand[:index:index], hand[index+1:]...)
+			}
+		case model.ActionClue:
+			clue := HanabLiveAction{Type: hanabLiveColorClue, Target: seats[action.TargetPlayer]}
+			if rank, err := strconv.Atoi(action.Clue); err == nil {
+				clue.Type = hanabLiveRankClue
+				clue.Value = rank
+			} else {
+				clue.Value = hanabLiveSuits[action.Clue]
+			}
+			if len(action.Card) == 0 {
+				replay.Options.EmptyClues = true
+			}
+			replay.Actions = append(replay.Actions, clue)
+		case model.ActionTimeout:
+			replay.Actions = append(replay.Actions, HanabLiveAction{
+				Type:   hanabLiveGameOver,
+				Target: seats[action.ActivePlayer],
+				Value:  hanabLiveTimeout,
+			})
+		case model.ActionLeave:
+			replay.Actions = append(replay.Actions, HanabLiveAction{
+				Type:   hanabLiveGameOver,
+				Target: seats[action.ActivePlayer],
+				Value:  hanabLiveTerminated,
+			})
+		case model.ActionAbandon:
+			replay.Actions = append(replay.Actions, HanabLiveAction{Type: hanabLiveGameOver, Value: hanabLiveIdleTimeout})
+		}
+	}
+	return replay, nil
+}
+
+func hanabLiveOptions(options model.GameOptions, players int) (HanabLiveOptions, error) {
+	variant, ok := hanabLiveVariants[options.Variant]
+	if !ok {
+		return HanabLiveOptions{}, fmt.Errorf("variant %s is not supported by hanab.live", options.Variant)
+	}
+	defaults := model.GameOptions{}.WithDefaults()
+	if options.MaxClues != defaults.MaxClues || options.MaxLives != defaults.MaxLives {
+		return HanabLiveOptions{}, fmt.Errorf("custom clues and lives are not supported by hanab.live")
+	}
+	if options.FullFinalRound {
+		return HanabLiveOptions{}, fmt.Errorf("a full final round is not supported by hanab.live")
+	}
+	handSize := options.CardsPerPlayer(players)
+	defaultHandSize := hanabLiveHandSize(players)
+	hanabLive := HanabLiveOptions{
+		Variant:      variant,
+		OneExtraCard: handSize == defaultHandSize+1,
+		OneLessCard:  handSize == defaultHandSize-1,
+	}
+	if !hanabLive.OneExtraCard && !hanabLive.OneLessCard && handSize != defaultHandSize {
+		return HanabLiveOptions{}, fmt.Errorf("hand size %d is not supported by hanab.live", handSize)
+	}
+	return hanabLive, nil
+}
+
+// hanabLiveHandSize is the number of cards hanab.live deals to each player, unless the hand size is changed
+// with one extra or one less card. It differs from the default of the server in games of six players.
+func hanabLiveHandSize(players int) int {
+	switch {
+	case players <= 3:
+		return 5
+	case players <= 5:
+		return 4
+	}
+	return 3
+}
+
+func hanabLiveCard(card model.Card) (HanabLiveCard, error) {
+	suit, ok := hanabLiveSuits[card.Color]
+	if !ok {
+		return HanabLiveCard{}, fmt.Errorf("unknown color %s", card.Color)
+	}
+	rank, err := strconv.Atoi(card.Value)
+	if err != nil {
+		return HanabLiveCard{}, fmt.Errorf("unknown value %s", card.Value)
+	}
+	return HanabLiveCard{SuitIndex: suit, Rank: rank}, nil
+}
+
+// WriteHanabLiveReplay writes the replay of a finished game to a new file in dir, and returns its path
+func WriteHanabLiveReplay(dir string, record model.GameRecord) (string, error) {
+	replay, err := NewHanabLiveReplay(record)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(replay, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// game ids are chosen by clients, and may be reused once a game has ended
+	name := fmt.Sprintf("%s-%d.json", url.PathEscape(string(record.Id)), time.Now().Unix())
+	path := filepath.Join(dir, name)
+	return path, ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func card(color, value string) model.Card {
+	return model.Card{Color: color, Value: value}
+}
+
+var record = model.GameRecord{
+	Id:      "review/me",
+	Options: model.GameOptions{}.WithDefaults(),
+	Players: []model.PlayerID{"Up", "Down"},
+	Deck: []model.Card{
+		card("W", "1"), card("W", "2"), card("W", "3"), card("W", "4"), card("W", "5"), // Up
+		card("R", "1"), card("B", "2"), card("G", "3"), card("Y", "4"), card("B", "5"), // Down
+		card("Y", "1"), card("G", "1"),
+	},
+	Actions: []model.Action{
+		{Type: model.ActionCreate, ActivePlayer: "Up"},
+		{Type: model.ActionStart, ActivePlayer: "Up"},
+		{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "R", Card: []int{0}},
+		{Type: model.ActionPlay, ActivePlayer: "Down", Card: []int{0}},
+		{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{2}},
+		{Type: model.ActionPlay, ActivePlayer: "Down", Card: []int{0}},
+		{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "1", Card: []int{}},
+	},
+}
+
+func TestNewHanabLiveReplay(t *testing.T) {
+	replay, err := NewHanabLiveReplay(record)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.PlayerID{"Up", "Down"}, replay.Players)
+	assert.Equal(t, HanabLiveOptions{Variant: "No Variant", EmptyClues: true}, replay.Options)
+	assert.Equal(t, 12, len(replay.Deck))
+	assert.Equal(t, HanabLiveCard{SuitIndex: 4, Rank: 1}, replay.Deck[0], "white is exported as purple")
+	assert.Equal(t, HanabLiveCard{SuitIndex: 0, Rank: 1}, replay.Deck[5])
+	assert.Equal(t, []HanabLiveAction{
+		{Type: hanabLiveColorClue, Target: 1, Value: 0}, // red to Down
+		{Type: hanabLivePlay, Target: 5},                // Down's first card
+		{Type: hanabLiveDiscard, Target: 2},             // Up's third card
+		{Type: hanabLivePlay, Target: 10},               // the card Down drew
+		{Type: hanabLiveRankClue, Target: 1, Value: 1},  // 1 to Down, touching nothing
+	}, replay.Actions)
+
+	// the server deals four cards to six players, and hanab.live deals three
+	sixPlayers := model.GameRecord{
+		Options: model.GameOptions{}.WithDefaults(),
+		Players: []model.PlayerID{"Up", "Down", "Top", "Bottom", "Strange", "Charm"},
+		Deck:    model.CreateDeck(model.VariantStandard, 1),
+	}
+	replay, err = NewHanabLiveReplay(sixPlayers)
+	assert.Nil(t, err)
+	assert.Equal(t, HanabLiveOptions{Variant: "No Variant", OneExtraCard: true}, replay.Options)
+
+	sixPlayers.Options = model.GameOptions{HandSize: 3}.WithDefaults()
+	replay, err = NewHanabLiveReplay(sixPlayers)
+	assert.Nil(t, err)
+	assert.Equal(t, HanabLiveOptions{Variant: "No Variant"}, replay.Options)
+}
+
+func TestNewHanabLiveReplay_Timeout(t *testing.T) {
+	timedOut := record
+	timedOut.Actions = append(record.Actions[:3:3], model.Action{Type: model.ActionTimeout, ActivePlayer: "Down"})
+	replay, err := NewHanabLiveReplay(timedOut)
+	assert.Nil(t, err)
+	assert.Equal(t, []HanabLiveAction{
+		{Type: hanabLiveColorClue, Target: 1, Value: 0},
+		{Type: hanabLiveGameOver, Target: 1, Value: hanabLiveTimeout},
+	}, replay.Actions)
+}
+
+func TestNewHanabLiveReplay_Unfinished(t *testing.T) {
+	testCases := []struct {
+		description string
+		action      model.Action
+		expected    HanabLiveAction
+	}{
+		{
+			description: "Left",
+			action:      model.Action{Type: model.ActionLeave, ActivePlayer: "Down"},
+			expected:    HanabLiveAction{Type: hanabLiveGameOver, Target: 1, Value: hanabLiveTerminated},
+		},
+		{
+			description: "Abandoned",
+			action:      model.Action{Type: model.ActionAbandon},
+			expected:    HanabLiveAction{Type: hanabLiveGameOver, Value: hanabLiveIdleTimeout},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			unfinished := record
+			unfinished.Actions = append(record.Actions[:3:3], tc.action)
+			replay, err := NewHanabLiveReplay(unfinished)
+			assert.Nil(t, err)
+			assert.Equal(t, []HanabLiveAction{
+				{Type: hanabLiveColorClue, Target: 1, Value: 0},
+				tc.expected,
+			}, replay.Actions)
+		})
+	}
+}
+
+func TestNewHanabLiveReplay_EmptyDeck(t *testing.T) {
+	// the last card was drawn on Up's first discard, so every later play or discard shrinks a hand
+	endgame := record
+	endgame.Actions = append(record.Actions[:6:6],
+		model.Action{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{3}},
+		model.Action{Type: model.ActionDiscard, ActivePlayer: "Down", Card: []int{0}},
+		model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{3}},
+	)
+	replay, err := NewHanabLiveReplay(endgame)
+	assert.Nil(t, err)
+	assert.Equal(t, []HanabLiveAction{
+		{Type: hanabLiveDiscard, Target: 3}, // Up's fourth card
+		{Type: hanabLiveDiscard, Target: 6}, // Down's first card, once the card Down played is gone
+		{Type: hanabLivePlay, Target: 4},    // Up's fifth card, which is now the fourth
+	}, replay.Actions[4:])
+
+	endgame.Actions = append(endgame.Actions, model.Action{Type: model.ActionDiscard, ActivePlayer: "Down", Card: []int{3}})
+	_, err = NewHanabLiveReplay(endgame)
+	assert.Equal(t, fmt.Errorf("discard of a card that is not in the hand of Down"), err)
+}
+
+func TestNewHanabLiveReplay_Unsupported(t *testing.T) {
+	houseRules := record
+	houseRules.Options = model.GameOptions{MaxLives: 1}.WithDefaults()
+	_, err := NewHanabLiveReplay(houseRules)
+	assert.Equal(t, fmt.Errorf("custom clues and lives are not supported by hanab.live"), err)
+
+	bigHands := record
+	bigHands.Options = model.GameOptions{HandSize: 2}.WithDefaults()
+	_, err = NewHanabLiveReplay(bigHands)
+	assert.Equal(t, fmt.Errorf("hand size 2 is not supported by hanab.live"), err)
+
+	fullFinalRound := record
+	fullFinalRound.Options = model.GameOptions{FullFinalRound: true}.WithDefaults()
+	_, err = NewHanabLiveReplay(fullFinalRound)
+	assert.Equal(t, fmt.Errorf("a full final round is not supported by hanab.live"), err)
+}
+
+func TestWriteHanabLiveReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hanabi-export")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path, err := WriteHanabLiveReplay(dir, record)
+	assert.Nil(t, err)
+	assert.Equal(t, dir, filepath.Dir(path), "the game id must not escape the directory")
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	replay := HanabLiveReplay{}
+	assert.Nil(t, json.Unmarshal(data, &replay))
+	assert.Equal(t, "No Variant", replay.Options.Variant)
+	assert.Equal(t, 5, len(replay.Actions))
+}
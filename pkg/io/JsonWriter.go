@@ -1,8 +1,8 @@
 package io
 
 import (
-	"encoding/json"
 	"net"
+	"sync"
 )
 
 type JsonWriter interface {
@@ -12,6 +12,9 @@ type JsonWriter interface {
 
 type jsonWriter struct {
 	conn net.Conn
+	// the messages are numbered in the order they are written
+	lock    sync.Mutex
+	encoder Encoder
 }
 
 func NewJsonWriter(conn net.Conn) JsonWriter {
@@ -20,10 +23,15 @@ func NewJsonWriter(conn net.Conn) JsonWriter {
 	}
 }
 
+// Write sends the message in an envelope, followed by a newline
 func (w *jsonWriter) Write(obj interface{}) (int, error) {
-	msg, _ := json.Marshal(obj)
-	msg = append(msg, '\n')
-	return w.conn.Write(msg)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	msg, err := w.encoder.Encode(obj)
+	if err != nil {
+		return 0, err
+	}
+	return w.conn.Write(append(msg, '\n'))
 }
 
 func (w *jsonWriter) Close() error {
@@ -0,0 +1,25 @@
+package io
+
+import (
+	"net"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+type tcpConnection struct {
+	ActionReader
+	JsonWriter
+}
+
+// NewConnection wraps a stream connection that sends newline delimited JSON messages
+func NewConnection(conn net.Conn) model.Connection {
+	return &tcpConnection{
+		ActionReader: NewActionReader(conn),
+		JsonWriter:   NewJsonWriter(conn),
+	}
+}
+
+func (c *tcpConnection) Close() error {
+	// reader and writer share the same underlying connection
+	return c.JsonWriter.Close()
+}
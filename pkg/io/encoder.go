@@ -0,0 +1,63 @@
+package io
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// ProtocolVersion is the version of the envelopes, and of the messages in them
+const ProtocolVersion = 1
+
+// Encoder wraps messages in envelopes of the current protocol version, and numbers them.
+// It is not safe for concurrent use, since the numbers must follow the order the messages are sent in.
+type Encoder struct {
+	seq int64
+}
+
+// Encode returns the message as a JSON envelope. Messages of unknown types are rejected.
+func (e *Encoder) Encode(message interface{}) ([]byte, error) {
+	messageType, err := MessageType(message)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", messageType, err)
+	}
+	e.seq++
+	return json.Marshal(model.Envelope{Version: ProtocolVersion, Type: messageType, Seq: e.seq, Payload: payload})
+}
+
+// MessageType returns the type of the envelope of a message
+func MessageType(message interface{}) (string, error) {
+	switch message.(type) {
+	case model.GameState, *model.GameState:
+		return model.MessageState, nil
+	case model.Update, *model.Update:
+		return model.MessageUpdate, nil
+	case model.Error, *model.Error:
+		return model.MessageError, nil
+	case model.ServerEvent, *model.ServerEvent:
+		return model.MessageEvent, nil
+	case model.Chat, *model.Chat:
+		return model.MessageChat, nil
+	case model.Lobby, *model.Lobby:
+		return model.MessageLobby, nil
+	}
+	return "", fmt.Errorf("unknown message type %T", message)
+}
+
+// Decode reads an envelope. It fails if the envelope is of another version of the protocol.
+func Decode(msg []byte) (model.Envelope, error) {
+	envelope := model.Envelope{}
+	err := json.Unmarshal(msg, &envelope)
+	if err != nil {
+		return envelope, fmt.Errorf("failed to decode message: %w", err)
+	}
+	if envelope.Version != ProtocolVersion {
+		return envelope, fmt.Errorf("unsupported protocol version %d", envelope.Version)
+	}
+	return envelope, nil
+}
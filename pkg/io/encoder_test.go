@@ -0,0 +1,69 @@
+package io
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestEncoder(t *testing.T) {
+	encoder := Encoder{}
+	testCases := []struct {
+		message  interface{}
+		expected string
+	}{
+		{
+			message:  model.GameState{Id: "go", Clues: 8},
+			expected: `{"version":1,"type":"state","seq":1,"payload":{"id":"go","players":null,"clues":8,"lives":0,"discards":null,"table":null,"deck":0,"turn":0,"seq":0,"playedAction":{"type":""},"started":false,"ended":false,"colors":0,"options":{}}}`,
+		},
+		{
+			message:  model.NewError(model.ErrNotYourTurn, "not your turn"),
+			expected: `{"version":1,"type":"error","seq":2,"payload":{"code":"not_your_turn","message":"not your turn"}}`,
+		},
+		{
+			message:  model.ServerEvent{Event: model.EventShutdown},
+			expected: `{"version":1,"type":"event","seq":3,"payload":{"event":"shutdown"}}`,
+		},
+		{
+			message:  model.Chat{Messages: []model.ChatMessage{{Player: "Up", Message: "hi"}}},
+			expected: `{"version":1,"type":"chat","seq":4,"payload":{"chat":[{"player":"Up","message":"hi"}]}}`,
+		},
+		{
+			message:  model.Lobby{Games: []model.GameSummary{}},
+			expected: `{"version":1,"type":"lobby","seq":5,"payload":{"games":[]}}`,
+		},
+		{
+			message:  &model.Update{Action: model.Action{Type: model.ActionClue}, Clues: 7, Started: true},
+			expected: `{"version":1,"type":"update","seq":6,"payload":{"seq":0,"action":{"type":"clue"},"clues":7,"lives":0,"deck":0,"turn":0,"started":true,"ended":false}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%T", tc.message), func(t *testing.T) {
+			msg, err := encoder.Encode(tc.message)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, string(msg))
+		})
+	}
+
+	_, err := encoder.Encode(map[string]string{"raw": "json"})
+	assert.Equal(t, fmt.Errorf("unknown message type map[string]string"), err)
+	msg, err := encoder.Encode(model.Lobby{})
+	assert.Nil(t, err)
+	envelope, err := Decode(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), envelope.Seq, "rejected messages are not numbered")
+}
+
+func TestDecode(t *testing.T) {
+	envelope, err := Decode([]byte(`{"version":1,"type":"lobby","seq":7,"payload":{"games":[]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, model.Envelope{Version: 1, Type: model.MessageLobby, Seq: 7, Payload: []byte(`{"games":[]}`)}, envelope)
+
+	_, err = Decode([]byte(`{"version":2,"type":"lobby","seq":7,"payload":{}}`))
+	assert.Equal(t, fmt.Errorf("unsupported protocol version 2"), err)
+	_, err = Decode([]byte(`{"id":"go"}`))
+	assert.NotNil(t, err, "messages without an envelope are rejected")
+}
@@ -1,7 +1,7 @@
 package io
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -21,64 +21,60 @@ type ActionReader interface {
 	Close() error
 }
 
+// MaxMessageSize is the longest line a reader accepts, without the newline. It leaves room for the
+// state of a full game, and for any action with every option and the longest chat message.
+const MaxMessageSize = 64 * 1024
+
 type modelReader struct {
 	conn    net.Conn
-	buffer  []byte
-	lastIdx int
+	scanner *bufio.Scanner
 }
 
-func NewGameStateReader(conn net.Conn) GameStateReader {
+func newModelReader(conn net.Conn) *modelReader {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), MaxMessageSize)
 	return &modelReader{
-		conn:   conn,
-		buffer: make([]byte, 1000),
+		conn:    conn,
+		scanner: scanner,
 	}
 }
 
+func NewGameStateReader(conn net.Conn) GameStateReader {
+	return newModelReader(conn)
+}
+
 func NewActionReader(conn net.Conn) ActionReader {
-	return &modelReader{
-		conn:   conn,
-		buffer: make([]byte, 200),
-	}
+	return newModelReader(conn)
 }
 
-const readTimeout = 30 //seconds
+// ReadTimeout closes connections that send nothing for this long. Clients ping to stay connected.
+// It must be set before connections are read.
+var ReadTimeout = 30 * time.Second
 
-func (r *modelReader) read(model interface{}) error {
-	bytesRead := 0
-	if r.lastIdx == 0 || bytes.IndexByte(r.buffer, '\n') < 0 {
-		err := r.conn.SetReadDeadline(time.Now().Add(time.Second * readTimeout))
-		if err != nil {
-			log.Warn("set read deadline failed")
+// read reads the next line. A line that is too long fails with a bad request, after which nothing
+// more can be read.
+func (r *modelReader) read(v interface{}) error {
+	err := r.conn.SetReadDeadline(time.Now().Add(ReadTimeout))
+	if err != nil {
+		log.Warn("set read deadline failed")
+	}
+	if !r.scanner.Scan() {
+		err = r.scanner.Err()
+		if err == bufio.ErrTooLong {
+			return model.NewError(model.ErrBadRequest, "message is longer than %d bytes", MaxMessageSize)
 		}
-		bytesRead, err = r.conn.Read(r.buffer[r.lastIdx:])
-		if err != nil {
-			return err
+		if err == nil {
+			return fmt.Errorf("connection closed by client")
 		}
+		return err
 	}
-	idx := bytes.IndexByte(r.buffer, '\n')
-	if idx == -1 {
-		r.lastIdx += bytesRead
-		return r.read(model)
-	}
-	defer r.rewindBuffer(idx + 1)
-	r.lastIdx += bytesRead - idx - 1
-	err := json.Unmarshal(r.buffer[:idx], model)
+	err = json.Unmarshal(r.scanner.Bytes(), v)
 	if err != nil {
 		return fmt.Errorf("unmarshalling failed: %w", err)
 	}
 	return nil
 }
 
-func (r *modelReader) rewindBuffer(idx int) {
-	i := 0
-	for ; i < len(r.buffer)-idx; i++ {
-		r.buffer[i] = r.buffer[i+idx]
-	}
-	for ; i < len(r.buffer); i++ {
-		r.buffer[i] = 0
-	}
-}
-
 func (r *modelReader) ReadGameState() (*model.GameState, error) {
 	state := model.GameState{}
 	err := r.read(&state)
@@ -0,0 +1,87 @@
+package io
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+type webSocketConnection struct {
+	conn *websocket.Conn
+	// a websocket supports only one concurrent writer, and the messages are numbered in the order they are written
+	writeLock sync.Mutex
+	encoder   Encoder
+}
+
+// NewWebSocketConnection wraps a websocket that sends one JSON message per text frame. Messages
+// longer than MaxMessageSize are not read.
+func NewWebSocketConnection(conn *websocket.Conn) model.Connection {
+	conn.SetReadLimit(MaxMessageSize)
+	return &webSocketConnection{
+		conn: conn,
+	}
+}
+
+func (c *webSocketConnection) ReadAction() (*model.Action, error) {
+	err := c.conn.SetReadDeadline(time.Now().Add(ReadTimeout))
+	if err != nil {
+		log.Warn("set read deadline failed")
+	}
+	_, msg, err := c.conn.ReadMessage()
+	if errors.Is(err, websocket.ErrReadLimit) {
+		// the websocket is closed by the limit, so nothing more can be read
+		return nil, model.NewError(model.ErrBadRequest, "message is longer than %d bytes", MaxMessageSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read action: %w", err)
+	}
+	action := model.Action{}
+	err = json.Unmarshal(msg, &action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read action: unmarshalling failed: %w", err)
+	}
+	return &action, nil
+}
+
+func (c *webSocketConnection) Write(obj interface{}) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	msg, err := c.encoder.Encode(obj)
+	if err != nil {
+		return 0, err
+	}
+	err = c.conn.WriteMessage(websocket.TextMessage, msg)
+	if err != nil {
+		return 0, err
+	}
+	return len(msg), nil
+}
+
+func (c *webSocketConnection) Close() error {
+	return c.conn.Close()
+}
+
+var upgrader = websocket.Upgrader{
+	// clients authenticate with session tokens inside the messages, not with cookies,
+	// so pages served from any origin may connect
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// NewWebSocketHandler upgrades incoming http requests to websockets, and passes them on to handle
+func NewWebSocketHandler(handle func(model.Connection)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Warn("websocket upgrade failed: ", err)
+			return
+		}
+		handle(NewWebSocketConnection(conn))
+	})
+}
@@ -0,0 +1,57 @@
+package io
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestWebSocketConnection(t *testing.T) {
+	received := make(chan *model.Action, 1)
+	server := httptest.NewServer(NewWebSocketHandler(func(conn model.Connection) {
+		defer conn.Close()
+		action, err := conn.ReadAction()
+		assert.Nil(t, err)
+		received <- action
+		_, err = conn.Write(model.GameState{Id: action.GameID})
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(`{"type":"join","game":"ws","activePlayer":"Up"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &model.Action{Type: model.ActionJoin, GameID: "ws", ActivePlayer: "Up"}, <-received)
+
+	msgType, msg, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, websocket.TextMessage, msgType)
+	assert.True(t, strings.HasPrefix(string(msg), `{"version":1,"type":"state","seq":1,"payload":{"id":"ws",`))
+	assert.False(t, strings.Contains(string(msg), "\n"))
+}
+
+func TestWebSocketConnection_LongMessage(t *testing.T) {
+	readErr := make(chan error, 1)
+	server := httptest.NewServer(NewWebSocketHandler(func(conn model.Connection) {
+		defer conn.Close()
+		_, err := conn.ReadAction()
+		readErr <- err
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(`{"type":"chat","message":"`+strings.Repeat("a", MaxMessageSize)+`"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, model.NewError(model.ErrBadRequest, "message is longer than %d bytes", MaxMessageSize), <-readErr)
+}
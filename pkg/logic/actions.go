@@ -1,25 +1,27 @@
 package logic
 
 import (
-	"fmt"
-	"net"
 	"regexp"
-
-	"github.com/egoon/hanabi-server/pkg/io"
+	"time"
+	"unicode/utf8"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/egoon/hanabi-server/pkg/bot"
 	"github.com/egoon/hanabi-server/pkg/model"
 )
 
-const (
-	maxLives = 3
-	maxClues = 8
-)
+// HandleGameActions runs a new game until it ends, and returns its history.
+func HandleGameActions(game *model.Game, deck []model.Card) model.GameRecord {
+	state := newGameState(game.Id, deck)
+	record := newGameRecord(game.Id, deck)
+	runGame(game, &state, deck, &record)
+	return record
+}
 
-func HandleGameActions(game *model.Game, deck []model.Card) {
-	state := model.GameState{
-		Id:           game.Id,
+func newGameState(id model.GameID, deck []model.Card) model.GameState {
+	return model.GameState{
+		Id:           id,
 		Players:      make([]model.Player, 0, 5),
 		Clues:        0,
 		Lives:        0,
@@ -31,90 +33,325 @@ func HandleGameActions(game *model.Game, deck []model.Card) {
 		Ended:        false,
 		Colors:       len(deck) / 10,
 	}
-	game.State = &state
+}
+
+func newGameRecord(id model.GameID, deck []model.Card) model.GameRecord {
+	// dealt and drawn cards are replaced in the hands, which share their backing array with the deck
+	deckCopy := make([]model.Card, len(deck))
+	copy(deckCopy, deck)
+	return model.GameRecord{Id: id, Deck: deckCopy}
+}
+
+// recordAction adds the action that was just handled to the record
+func recordAction(record *model.GameRecord, state *model.GameState) {
+	switch state.PlayedAction.Type {
+	case model.ActionPing, model.ActionJoin, model.ActionAddBot:
+		return
+	case model.ActionLeave, model.ActionAbandon:
+		if !state.Started {
+			// like a join, leaving a game that has not started only changes the seats
+			return
+		}
+	case model.ActionCreate:
+		record.Options = state.Options
+		if state.Seed != nil {
+			record.Seed = *state.Seed
+		}
+	case model.ActionStart:
+		// the players are rotated every turn, so the seats are taken before the first turn
+		record.Players = make([]model.PlayerID, len(state.Players))
+		for i, player := range state.Players {
+			record.Players[i] = player.Id
+		}
+	}
+	record.Actions = append(record.Actions, state.PlayedAction)
+}
+
+func runGame(game *model.Game, state *model.GameState, deck []model.Card, record *model.GameRecord) {
+	game.State = state
+	summary := state.Summary()
+	if len(state.Players) > 0 {
+		// a restored game. The snapshot of a new game is set once its create is handled.
+		game.SetSnapshot(state)
+		publishSummary(game, summary)
+	}
+	// the history of a restored game starts when it is restored
+	history := &updateHistory{}
+	var clock *turnClock
+	if state.Started {
+		clock = newTurnClock(state.Options, state.Players)
+	}
+	if clock != nil {
+		clock.startTurn(state.Players[0].Id, time.Now())
+	}
+	var idleCheck <-chan time.Time
+	if game.IdleTimeout > 0 {
+		ticker := time.NewTicker(idleCheckInterval(game.IdleTimeout))
+		defer ticker.Stop()
+		idleCheck = ticker.C
+	}
+	// idleSince is when the game was first seen without connections, or zero if it has connections
+	var idleSince time.Time
 	for {
-		action := <-game.Actions
-		deck = handleAction(action, &state, deck)
-		sendStateToPlayers(&state, game.Connections)
+		var action *model.Action
+		var expired <-chan time.Time
+		if clock != nil {
+			expired = clock.expired()
+		}
+		select {
+		case action = <-game.Actions:
+		case <-expired:
+			action = timeoutAction(state, record)
+			log.Info("Player ", action.ActivePlayer, " ran out of time in game ", game.Id)
+		case now := <-idleCheck:
+			if isConnected(game) {
+				idleSince = time.Time{}
+				continue
+			}
+			if idleSince.IsZero() {
+				idleSince = now
+			}
+			if now.Sub(idleSince) < game.IdleTimeout {
+				continue
+			}
+			action = &model.Action{Type: model.ActionAbandon}
+			log.Info("Abandoning game ", game.Id, " after ", game.IdleTimeout, " without connections")
+		}
+		if action.IsTurn() && (state.Ended || !state.Started || state.Players[0].Id != action.ActivePlayer) {
+			// the turn was validated against a snapshot, but ended before the action arrived, e.g. by a timeout
+			log.Info("Ignoring ", action.Type, " by ", action.ActivePlayer, " after the turn ended")
+			continue
+		}
+		if action.Type == model.ActionJoin && state.Started && !state.HasPlayer(action.ActivePlayer) {
+			// the player was let in before the start was handled, and gets no seat
+			rejectJoin(game, action)
+			continue
+		}
+		if action.Type == model.ActionSpectate {
+			// a spectator has no effect on the game, and only the new spectator needs the state
+			spectator := map[model.PlayerID]model.Connection{
+				action.ActivePlayer: game.CopySpectators()[action.ActivePlayer],
+			}
+			sendStateToSpectators(state, spectator)
+			sendChatHistory(game, spectator)
+			continue
+		}
+		if action.Type == model.ActionResync {
+			resync(state, history, action, game.CopyConnections(), game.CopySpectators())
+			continue
+		}
+		if game.Log != nil && action.Type != model.ActionPing {
+			// logged before it is handled, since handling adds the touched cards to clues
+			logError(game, game.Log.WriteAction(action))
+		}
+		if action.Type == model.ActionChat {
+			// chat is not a move in the game, and is sent on its own
+			message := addChatMessage(game, action)
+			chat := model.Chat{Messages: []model.ChatMessage{message}}
+			sendToAll(chat, game.CopyConnections())
+			sendToAll(chat, game.CopySpectators())
+			continue
+		}
+		before := pileSizesOf(state)
+		deck = handleAction(action, state, deck)
+		recordAction(record, state)
+		countAction(state)
+		if state.PlayedAction.Type == model.ActionStart {
+			clock = newTurnClock(state.Options, state.Players)
+		}
+		if clock != nil {
+			now := time.Now()
+			if state.PlayedAction.IsTurn() {
+				clock.endTurn(now)
+			}
+			if state.PlayedAction.IsTurn() || state.PlayedAction.Type == model.ActionStart {
+				clock.startTurn(state.Players[0].Id, now)
+			}
+			if state.Ended {
+				clock.stop()
+			}
+			state.Clock = clock.report(now)
+		}
+		game.SetSnapshot(state)
+		if state.Summary() != summary {
+			summary = state.Summary()
+			publishSummary(game, summary)
+		}
+		update := newUpdate(state, before)
+		if state.PlayedAction.Type != model.ActionPing && state.PlayedAction.Type != model.ActionCreate {
+			// pings change nothing, and the options are not in the update of a create, so neither is sent again
+			history.add(update)
+		}
+		connections := game.CopyConnections()
+		spectators := game.CopySpectators()
+		switch state.PlayedAction.Type {
+		case model.ActionCreate, model.ActionPing:
+			sendStateToPlayers(state, connections)
+			sendStateToSpectators(state, spectators)
+		case model.ActionJoin, model.ActionAddBot:
+			// a new or returning player needs the whole state, while the others only need the new seat
+			joined := state.PlayedAction.ActivePlayer
+			if state.PlayedAction.Type == model.ActionAddBot {
+				joined = state.PlayedAction.TargetPlayer
+			}
+			others := make(map[model.PlayerID]model.Connection, len(connections))
+			for playerID, conn := range connections {
+				if playerID != joined {
+					others[playerID] = conn
+				}
+			}
+			sendStateToPlayers(state, map[model.PlayerID]model.Connection{joined: connections[joined]})
+			sendUpdates(update, others, spectators)
+		default:
+			sendUpdates(update, connections, spectators)
+		}
+		if state.PlayedAction.Type == model.ActionJoin {
+			sendChatHistory(game, map[model.PlayerID]model.Connection{
+				state.PlayedAction.ActivePlayer: connections[state.PlayedAction.ActivePlayer],
+			})
+		}
 		if state.Ended {
-			for _, c := range game.Connections {
+			for _, c := range connections {
+				if c != nil {
+					_ = c.Close()
+				}
+			}
+			for _, c := range spectators {
 				_ = c.Close()
 			}
+			if game.Log != nil {
+				_ = game.Log.Close(true)
+			}
 			log.Info("Game ", game.Id, " score: ", len(game.State.Table))
 			break
 		}
 	}
 }
 
+// isConnected returns true if a player or spectator is connected to the game. Bots are not
+// counted, since they only answer the game.
+func isConnected(game *model.Game) bool {
+	game.Lock()
+	defer game.Unlock()
+	for _, conn := range game.Connections {
+		if _, isBot := conn.(*bot.Bot); conn != nil && !isBot {
+			return true
+		}
+	}
+	return len(game.Spectators) > 0
+}
+
+// countAction numbers the action that was just handled. Pings are not counted, since they change nothing.
+func countAction(state *model.GameState) {
+	if state.PlayedAction.Type != model.ActionPing {
+		state.Seq++
+	}
+}
+
+func publishSummary(game *model.Game, summary model.GameSummary) {
+	if game.Publish != nil {
+		game.Publish(summary)
+	}
+}
+
 func handleAction(action *model.Action, state *model.GameState, deck []model.Card) []model.Card {
+	options := state.Options.WithDefaults()
+	cardsLeft := len(deck)
 	switch action.Type {
 	case model.ActionPing:
 		// do nothing
+	case model.ActionCreate:
+		if action.Options != nil {
+			options = *action.Options
+		}
+		state.Options = options.WithDefaults()
+		// the seed reveals the deck, so it is kept out of the played action
+		state.Seed = action.Seed
+		action.Seed = nil
+		state.Players = append(state.Players, model.Player{Id: action.ActivePlayer})
 	case model.ActionJoin:
-		if len(state.Players) < 5 && !state.Started {
-			state.Players = append(state.Players, model.Player{Id: action.ActivePlayer})
-		}
+		seatPlayer(state, action.ActivePlayer, options)
+	case model.ActionAddBot:
+		seatPlayer(state, action.TargetPlayer, options)
 	case model.ActionStart:
-		cardsPerPlayer := 5
-		if len(state.Players) > 3 {
-			cardsPerPlayer = 4
-		}
+		cardsPerPlayer := options.CardsPerPlayer(len(state.Players))
 		for i := range state.Players {
 			state.Players[i].Cards = deck[:cardsPerPlayer]
+			state.Players[i].Knowledge = make([]model.CardKnowledge, cardsPerPlayer)
 			deck = deck[cardsPerPlayer:]
 		}
-		state.Clues = maxClues
-		state.Lives = maxLives
+		state.Clues = options.MaxClues
+		state.Lives = options.MaxLives
 		state.Started = true
 		state.Deck = len(deck)
 	case model.ActionClue:
 		state.Clues--
-		for _, player := range state.Players {
+		for p := range state.Players {
+			player := &state.Players[p]
 			if player.Id == action.TargetPlayer {
+				if len(player.Knowledge) < len(player.Cards) {
+					knowledge := make([]model.CardKnowledge, len(player.Cards))
+					copy(knowledge, player.Knowledge)
+					player.Knowledge = knowledge
+				}
 				for i, card := range player.Cards {
-					if card.Color == action.Clue || card.Value == action.Clue {
+					touched := card.IsTouchedBy(action.Clue)
+					if touched {
 						action.Card = append(action.Card, i)
 					}
+					player.Knowledge[i].AddClue(action.Clue, touched)
 				}
 				break
 			}
 		}
 	case model.ActionPlay:
-		hand := state.Players[0].Cards
-		card := hand[action.Card[0]]
-		if isCardPlayable(card, state.Table) {
+		card := state.Players[0].Cards[action.Card[0]]
+		if state.IsPlayable(card) {
 			state.Table = append(state.Table, card)
-			if card.Value == "5" && state.Clues < maxClues {
+			if card.Value == "5" && state.Clues < options.MaxClues {
 				state.Clues++
 			}
 		} else {
 			state.Discards = append(state.Discards, card)
 			state.Lives--
 		}
-		hand[action.Card[0]], deck = drawCard(deck)
+		deck = replaceCard(&state.Players[0], action.Card[0], deck)
+		state.Deck = len(deck)
 		if len(state.Table) == state.Colors*5 || state.Lives == 0 {
 			state.Ended = true
 		}
-		state.Players[0].Cards = hand
 	case model.ActionDiscard:
-		hand := state.Players[0].Cards
-		card := hand[action.Card[0]]
+		card := state.Players[0].Cards[action.Card[0]]
 		state.Discards = append(state.Discards, card)
-		hand[action.Card[0]], deck = drawCard(deck)
-		state.Players[0].Cards = hand
-		if state.Clues < maxClues {
+		deck = replaceCard(&state.Players[0], action.Card[0], deck)
+		state.Deck = len(deck)
+		if state.Clues < options.MaxClues {
 			state.Clues++
 		}
+	case model.ActionLeave:
+		if state.Started {
+			// the game can't go on without the player
+			state.Ended = true
+		} else {
+			removePlayer(state, action.ActivePlayer)
+			state.Ended = len(state.Players) == 0
+		}
+	case model.ActionTimeout, model.ActionAbandon:
+		state.Ended = true
 	}
 	if len(deck) > 0 {
 		state.Deck = len(deck)
 	}
-	if action.Type == model.ActionPlay || action.Type == model.ActionClue || action.Type == model.ActionDiscard {
-		if len(deck) == 0 {
-			state.Deck--
-		}
-		if -state.Deck == len(state.Players) {
-			state.Ended = true
+	if action.IsTurn() {
+		state.Turn++
+		if state.TurnsRemaining > 0 {
+			state.TurnsRemaining--
+			if state.TurnsRemaining == 0 {
+				state.Ended = true
+			}
+		} else if cardsLeft > 0 && len(deck) == 0 {
+			// the last card was drawn on this turn
+			state.TurnsRemaining = finalTurns(state, options)
 		}
 		state.Players = append(state.Players[1:], state.Players[0])
 	}
@@ -122,21 +359,46 @@ func handleAction(action *model.Action, state *model.GameState, deck []model.Car
 	return deck
 }
 
-func isCardPlayable(card model.Card, table []model.Card) bool {
-	requiredCardPlayed := card.Value == "1"
-	for _, c := range table {
-		if c == card {
-			return false
-		}
-		if c.Color == card.Color &&
-			(c.Value == "1" && card.Value == "2" ||
-				c.Value == "2" && card.Value == "3" ||
-				c.Value == "3" && card.Value == "4" ||
-				c.Value == "4" && card.Value == "5") {
-			requiredCardPlayed = true
+// seatPlayer adds a player to a game that has not started, unless the player is already seated
+func seatPlayer(state *model.GameState, playerID model.PlayerID, options model.GameOptions) {
+	if len(state.Players) < options.MaxPlayers && !state.Started && !state.HasPlayer(playerID) {
+		state.Players = append(state.Players, model.Player{Id: playerID})
+	}
+}
+
+// removePlayer gives up the seat of a player in a game that has not started
+func removePlayer(state *model.GameState, playerID model.PlayerID) {
+	for i, player := range state.Players {
+		if player.Id == playerID {
+			state.Players = append(state.Players[:i:i], state.Players[i+1:]...)
+			return
 		}
 	}
-	return requiredCardPlayed
+}
+
+// replaceCard replaces a played or discarded card with the next card of the deck, and clears what the
+// player knew about it. Once the deck is empty the hand shrinks instead. It returns the rest of the deck.
+func replaceCard(player *model.Player, index int, deck []model.Card) []model.Card {
+	if len(deck) == 0 {
+		player.RemoveCard(index)
+		return deck
+	}
+	player.Cards[index] = deck[0]
+	if index < len(player.Knowledge) {
+		player.Knowledge[index] = model.CardKnowledge{}
+	}
+	return deck[1:]
+}
+
+// finalTurns returns the number of turns that are left after the last card is drawn. Every player
+// gets one more turn, and with a full final round the game goes on to the end of that round.
+func finalTurns(state *model.GameState, options model.GameOptions) int {
+	players := len(state.Players)
+	if !options.FullFinalRound {
+		return players
+	}
+	// the first round started with the first seat, and state.Turn turns have been played
+	return players + (players-state.Turn%players)%players
 }
 
 func ValidateAndCleanAction(action *model.Action, state *model.GameState) error {
@@ -146,129 +408,323 @@ func ValidateAndCleanAction(action *model.Action, state *model.GameState) error
 		action.Clue = ""
 		action.GameID = ""
 		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
+	case model.ActionResync:
+		if action.Since < 0 {
+			return model.NewError(model.ErrInvalidAction, "resync action may not have a negative since")
+		}
+		action.Card = nil
+		action.Clue = ""
+		action.GameID = ""
+		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
 	case model.ActionCreate:
 		if state != nil {
-			return fmt.Errorf("already connected to a game")
+			return model.NewError(model.ErrAlreadyInGame, "already connected to a game")
+		}
+		if action.Options != nil {
+			err := validateOptions(action.Options)
+			if err != nil {
+				return err
+			}
 		}
 		action.Card = nil
 		action.Clue = ""
 		action.TargetPlayer = ""
+		action.Token = ""
+		action.Message = ""
+		action.Since = 0
 	case model.ActionJoin:
 		if state != nil {
-			return fmt.Errorf("already connected to a game")
+			return model.NewError(model.ErrAlreadyInGame, "already connected to a game")
 		}
 		if action.GameID == "" {
-			return fmt.Errorf("join action must have game id")
+			return model.NewError(model.ErrInvalidAction, "join action must have game id")
 		}
 		action.Card = nil
 		action.Clue = ""
 		action.TargetPlayer = ""
-	case model.ActionStart:
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
+	case model.ActionListGames, model.ActionSubscribeLobby:
+		if state != nil {
+			return model.NewError(model.ErrAlreadyInGame, "already connected to a game")
+		}
+		action.Card = nil
+		action.Clue = ""
+		action.GameID = ""
+		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
+	case model.ActionChat:
+		// chat is not a move, so it is allowed whoever's turn it is
 		if state == nil {
-			return fmt.Errorf("not connected to a game")
+			return model.NewError(model.ErrNotInGame, "not connected to a game")
+		}
+		if action.Message == "" {
+			return model.NewError(model.ErrInvalidAction, "chat action must have a message")
+		}
+		if utf8.RuneCountInString(action.Message) > maxChatLength {
+			return model.NewError(model.ErrInvalidAction, "chat message may not be longer than %d characters", maxChatLength)
+		}
+		action.Card = nil
+		action.Clue = ""
+		action.GameID = ""
+		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Since = 0
+	case model.ActionSpectate:
+		if state != nil {
+			return model.NewError(model.ErrAlreadyInGame, "already connected to a game")
+		}
+		if action.GameID == "" {
+			return model.NewError(model.ErrInvalidAction, "spectate action must have game id")
+		}
+		action.Card = nil
+		action.Clue = ""
+		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
+	case model.ActionLeave:
+		// leaving is not a move, so it is allowed whoever's turn it is
+		if state == nil {
+			return model.NewError(model.ErrNotInGame, "not connected to a game")
+		}
+		action.Card = nil
+		action.Clue = ""
+		action.GameID = ""
+		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
+	case model.ActionAddBot:
+		if err := checkPlaying(state); err != nil {
+			return err
 		}
 		if state.Started {
-			return fmt.Errorf("game already started")
+			return model.NewError(model.ErrGameStarted, "game already started")
 		}
 		if state.Players[0].Id != action.ActivePlayer {
-			return fmt.Errorf("only creator may start game")
+			return model.NewError(model.ErrNotCreator, "only creator may add bots")
 		}
-		if len(state.Players) < 2 {
-			return fmt.Errorf("too few players")
+		// the target player is the name of the bot, and may be left for the server to pick
+		action.Card = nil
+		action.Clue = ""
+		action.GameID = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
+	case model.ActionStart:
+		if err := checkPlaying(state); err != nil {
+			return err
+		}
+		if state.Started {
+			return model.NewError(model.ErrGameStarted, "game already started")
+		}
+		if state.Players[0].Id != action.ActivePlayer {
+			return model.NewError(model.ErrNotCreator, "only creator may start game")
+		}
+		if len(state.Players) < state.Options.WithDefaults().MinPlayers {
+			return model.NewError(model.ErrTooFewPlayers, "too few players")
 		}
 		action.Card = nil
 		action.Clue = ""
 		action.GameID = ""
 		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
 	case model.ActionClue:
-		if state == nil {
-			return fmt.Errorf("not connected to a game")
+		if err := checkPlaying(state); err != nil {
+			return err
 		}
 		if !state.Started {
-			return fmt.Errorf("game is not started")
+			return model.NewError(model.ErrGameNotStarted, "game is not started")
 		}
 		if state.Players[0].Id != action.ActivePlayer {
-			return fmt.Errorf("not your turn")
+			return model.NewError(model.ErrNotYourTurn, "not your turn")
 		}
 		if state.Clues < 1 {
-			return fmt.Errorf("there are no clues available to give")
+			return model.NewError(model.ErrNoClues, "there are no clues available to give")
 		}
+		// rainbow cards are touched by color clues, but rainbow itself is not a clue
 		pattern := `^[12345BGRWY]$`
 		match, _ := regexp.MatchString(pattern, action.Clue)
 		if !match {
-			return fmt.Errorf("clue action must have clue field that matches %s", pattern)
+			return model.NewError(model.ErrInvalidAction, "clue action must have clue field that matches %s", pattern)
 		}
 		if !state.HasPlayer(action.TargetPlayer) {
-			return fmt.Errorf("player %s is not in this game", action.TargetPlayer)
+			return model.NewError(model.ErrPlayerNotFound, "player %s is not in this game", action.TargetPlayer)
 		}
 		if action.TargetPlayer == action.ActivePlayer {
-			return fmt.Errorf("you may not target yourself")
+			return model.NewError(model.ErrInvalidAction, "you may not target yourself")
 		}
 		action.GameID = ""
 		action.Card = make([]int, 5)[:0]
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
 	case model.ActionPlay:
-		if state == nil {
-			return fmt.Errorf("not connected to a game")
+		if err := checkPlaying(state); err != nil {
+			return err
 		}
 		if !state.Started {
-			return fmt.Errorf("game is not started")
+			return model.NewError(model.ErrGameNotStarted, "game is not started")
 		}
 		if state.Players[0].Id != action.ActivePlayer {
-			return fmt.Errorf("not your turn")
+			return model.NewError(model.ErrNotYourTurn, "not your turn")
 		}
 		if len(action.Card) != 1 {
-			return fmt.Errorf("exactly 1 card must be played. Not %d", len(action.Card))
+			return model.NewError(model.ErrInvalidAction, "exactly 1 card must be played. Not %d", len(action.Card))
 		}
 		if action.Card[0] < 0 || action.Card[0] >= len(state.Players[0].Cards) {
-			return fmt.Errorf("no card on index %d", action.Card[0])
+			return model.NewError(model.ErrInvalidAction, "no card on index %d", action.Card[0])
 		}
 		action.GameID = ""
 		action.Clue = ""
 		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
 	case model.ActionDiscard:
-		if state == nil {
-			return fmt.Errorf("not connected to a game")
+		if err := checkPlaying(state); err != nil {
+			return err
 		}
 		if !state.Started {
-			return fmt.Errorf("game is not started")
+			return model.NewError(model.ErrGameNotStarted, "game is not started")
 		}
 		if state.Players[0].Id != action.ActivePlayer {
-			return fmt.Errorf("not your turn")
+			return model.NewError(model.ErrNotYourTurn, "not your turn")
 		}
 		if len(action.Card) != 1 {
-			return fmt.Errorf("exactly 1 card must be discarded. Not %d", len(action.Card))
+			return model.NewError(model.ErrInvalidAction, "exactly 1 card must be discarded. Not %d", len(action.Card))
 		}
 		if action.Card[0] < 0 || action.Card[0] >= len(state.Players[0].Cards) {
-			return fmt.Errorf("no card on index %d", action.Card[0])
+			return model.NewError(model.ErrInvalidAction, "no card on index %d", action.Card[0])
 		}
 		action.GameID = ""
 		action.Clue = ""
 		action.TargetPlayer = ""
+		action.Token = ""
+		action.Options = nil
+		action.Seed = nil
+		action.Message = ""
+		action.Since = 0
 	default:
-		return fmt.Errorf("unknown action: %s", action.Type)
+		return model.NewError(model.ErrUnknownAction, "unknown action: %s", action.Type)
+	}
+	return nil
+}
+
+// the number of times a game checks for connections within its idle timeout
+const idleChecks = 4
+
+// idleCheckInterval is how often a game checks for connections. It is never zero, which a ticker
+// does not accept, even for the shortest timeouts.
+func idleCheckInterval(timeout time.Duration) time.Duration {
+	interval := timeout / idleChecks
+	if interval < time.Millisecond {
+		return time.Millisecond
+	}
+	return interval
+}
+
+// checkPlaying returns an error unless the state is of a game that can still be played. A game has no
+// players before its create is handled, or after the last player has left.
+func checkPlaying(state *model.GameState) error {
+	if state == nil || len(state.Players) == 0 {
+		return model.NewError(model.ErrNotInGame, "not connected to a game")
+	}
+	if state.Ended {
+		return model.NewError(model.ErrNotInGame, "game has ended")
 	}
 	return nil
 }
 
-func sendStateToPlayers(state *model.GameState, connections map[model.PlayerID]net.Conn) {
+const (
+	maxHandSize = 6
+	maxPlayers  = 6
+)
+
+func validateOptions(options *model.GameOptions) error {
+	if options.Variant != model.VariantStandard && options.Variant != model.VariantRainbow {
+		return model.NewError(model.ErrInvalidOptions, "unknown variant: %s", options.Variant)
+	}
+	if options.HandSize < 0 || options.HandSize > maxHandSize {
+		return model.NewError(model.ErrInvalidOptions, "hand size must be between 1 and %d", maxHandSize)
+	}
+	if options.MaxLives < 0 {
+		return model.NewError(model.ErrInvalidOptions, "max lives must be positive")
+	}
+	if options.MaxClues < 0 {
+		return model.NewError(model.ErrInvalidOptions, "max clues must be positive")
+	}
+	if options.TurnSeconds < 0 || options.BankSeconds < 0 {
+		return model.NewError(model.ErrInvalidOptions, "turn seconds and bank seconds must be positive")
+	}
+	if options.OnTimeout != "" && options.OnTimeout != model.TimeoutDiscard && options.OnTimeout != model.TimeoutEnd {
+		return model.NewError(model.ErrInvalidOptions, "on timeout must be %s or %s", model.TimeoutDiscard, model.TimeoutEnd)
+	}
+	if options.FullFinalRound && options.HandSize == 1 {
+		// a player may have two turns after the last card is drawn, and must hold a card for each
+		return model.NewError(model.ErrInvalidOptions, "a full final round needs a hand size of at least 2")
+	}
+	withDefaults := options.WithDefaults()
+	if withDefaults.MinPlayers < 2 || withDefaults.MaxPlayers > maxPlayers || withDefaults.MinPlayers > withDefaults.MaxPlayers {
+		return model.NewError(model.ErrInvalidOptions, "players must be between 2 and %d, and min players may not exceed max players", maxPlayers)
+	}
+	return nil
+}
+
+func sendStateToPlayers(state *model.GameState, connections map[model.PlayerID]model.Connection) {
 	for playerId, conn := range connections {
-		if state.PlayedAction.Type == "ping" && state.PlayedAction.ActivePlayer != playerId {
+		if conn == nil || state.PlayedAction.Type == "ping" && state.PlayedAction.ActivePlayer != playerId {
 			// only respond to player who pinged
 			continue
 		}
 		playerState, _ := state.ForPlayer(playerId)
-		_, err := io.NewJsonWriter(conn).Write(playerState)
+		_, err := conn.Write(playerState)
 		if err != nil {
 			log.Error("failed to write state to player")
 		}
 	}
 }
 
-func drawCard(cards []model.Card) (model.Card, []model.Card) {
-	if len(cards) > 0 {
-		card := cards[0]
-		cards := cards[1:]
-		return card, cards
+func sendStateToSpectators(state *model.GameState, spectators map[model.PlayerID]model.Connection) {
+	for spectatorID, conn := range spectators {
+		if conn == nil || state.PlayedAction.Type == model.ActionPing && state.PlayedAction.ActivePlayer != spectatorID {
+			continue
+		}
+		_, err := conn.Write(state.ForSpectator())
+		if err != nil {
+			log.Error("failed to write state to spectator")
+		}
 	}
-	return model.Card{Color: "-", Value: "-"}, cards
 }
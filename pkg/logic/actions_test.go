@@ -2,44 +2,45 @@ package logic
 
 import (
 	"encoding/json"
-	"fmt"
-	"net"
+	"strings"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/egoon/hanabi-server/pkg/io"
 	"github.com/egoon/hanabi-server/pkg/model"
 )
 
 var (
-	b1     = model.Card{Color: "B", Value: "1"}
-	b2     = model.Card{Color: "B", Value: "2"}
-	b3     = model.Card{Color: "B", Value: "3"}
-	b4     = model.Card{Color: "B", Value: "4"}
-	b5     = model.Card{Color: "B", Value: "5"}
-	g1     = model.Card{Color: "G", Value: "1"}
-	g2     = model.Card{Color: "G", Value: "2"}
-	g3     = model.Card{Color: "G", Value: "3"}
-	g4     = model.Card{Color: "G", Value: "4"}
-	g5     = model.Card{Color: "G", Value: "5"}
-	r1     = model.Card{Color: "R", Value: "1"}
-	r2     = model.Card{Color: "R", Value: "2"}
-	r3     = model.Card{Color: "R", Value: "3"}
-	r4     = model.Card{Color: "R", Value: "4"}
-	r5     = model.Card{Color: "R", Value: "5"}
-	w1     = model.Card{Color: "W", Value: "1"}
-	w2     = model.Card{Color: "W", Value: "2"}
-	w3     = model.Card{Color: "W", Value: "3"}
-	w4     = model.Card{Color: "W", Value: "4"}
-	w5     = model.Card{Color: "W", Value: "5"}
-	y1     = model.Card{Color: "Y", Value: "1"}
-	y2     = model.Card{Color: "Y", Value: "2"}
-	y3     = model.Card{Color: "Y", Value: "3"}
-	y4     = model.Card{Color: "Y", Value: "4"}
-	y5     = model.Card{Color: "Y", Value: "5"}
-	noCard = model.Card{Color: "-", Value: "-"}
+	b1 = model.Card{Color: "B", Value: "1"}
+	b2 = model.Card{Color: "B", Value: "2"}
+	b3 = model.Card{Color: "B", Value: "3"}
+	b4 = model.Card{Color: "B", Value: "4"}
+	b5 = model.Card{Color: "B", Value: "5"}
+	g1 = model.Card{Color: "G", Value: "1"}
+	g2 = model.Card{Color: "G", Value: "2"}
+	g3 = model.Card{Color: "G", Value: "3"}
+	g4 = model.Card{Color: "G", Value: "4"}
+	g5 = model.Card{Color: "G", Value: "5"}
+	r1 = model.Card{Color: "R", Value: "1"}
+	r2 = model.Card{Color: "R", Value: "2"}
+	r3 = model.Card{Color: "R", Value: "3"}
+	r4 = model.Card{Color: "R", Value: "4"}
+	r5 = model.Card{Color: "R", Value: "5"}
+	w1 = model.Card{Color: "W", Value: "1"}
+	w2 = model.Card{Color: "W", Value: "2"}
+	w3 = model.Card{Color: "W", Value: "3"}
+	w4 = model.Card{Color: "W", Value: "4"}
+	w5 = model.Card{Color: "W", Value: "5"}
+	y1 = model.Card{Color: "Y", Value: "1"}
+	y2 = model.Card{Color: "Y", Value: "2"}
+	y3 = model.Card{Color: "Y", Value: "3"}
+	y4 = model.Card{Color: "Y", Value: "4"}
+	y5 = model.Card{Color: "Y", Value: "5"}
+	m1 = model.Card{Color: model.ColorRainbow, Value: "1"}
+	m5 = model.Card{Color: model.ColorRainbow, Value: "5"}
 )
 
 func TestValidateAndCleanAction(t *testing.T) {
@@ -67,6 +68,8 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "Dirty",
 				Card:         []int{1, 2, 3},
 				Clue:         "Dirty",
+				Token:        "Dirty",
+				Seed:         seedOf(7),
 			},
 			state: &model.GameState{},
 			expectedAction: model.Action{
@@ -75,6 +78,52 @@ func TestValidateAndCleanAction(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		//RESYNC
+		{
+			description: "Dirty Resync - OK",
+			action: model.Action{
+				Type:         model.ActionResync,
+				GameID:       "Dirty",
+				ActivePlayer: "Active Player",
+				Card:         []int{1},
+				Since:        3,
+			},
+			state:          &model.GameState{},
+			expectedAction: model.Action{Type: model.ActionResync, ActivePlayer: "Active Player", Since: 3},
+			expectedError:  nil,
+		},
+		{
+			description:    "Resync negative - FAIL",
+			action:         model.Action{Type: model.ActionResync, Since: -1},
+			state:          &model.GameState{},
+			expectedAction: model.Action{Type: model.ActionResync, Since: -1},
+			expectedError:  model.NewError(model.ErrInvalidAction, "resync action may not have a negative since"),
+		},
+		//LEAVE
+		{
+			description: "Dirty Leave - OK: not your turn",
+			action: model.Action{
+				Type:         model.ActionLeave,
+				ActivePlayer: "Me",
+				GameID:       "Dirty",
+				TargetPlayer: "Dirty",
+				Card:         []int{1},
+				Message:      "Dirty",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "You"}, {Id: "Me"}},
+				Started: true,
+			},
+			expectedAction: model.Action{Type: model.ActionLeave, ActivePlayer: "Me"},
+			expectedError:  nil,
+		},
+		{
+			description:    "Leave - Fail: no game",
+			action:         model.Action{Type: model.ActionLeave, ActivePlayer: "Me"},
+			state:          nil,
+			expectedAction: model.Action{Type: model.ActionLeave, ActivePlayer: "Me"},
+			expectedError:  model.NewError(model.ErrNotInGame, "not connected to a game"),
+		},
 		{
 			description: "Clean Create - OK",
 			action: model.Action{
@@ -106,6 +155,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "Dirty",
 				Card:         []int{1, 2, 3},
 				Clue:         "Dirty",
+				Token:        "Dirty",
 			},
 			state: nil,
 			expectedAction: model.Action{
@@ -137,7 +187,109 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Card:         nil,
 				Clue:         "",
 			},
-			expectedError: fmt.Errorf("already connected to a game"),
+			expectedError: model.NewError(model.ErrAlreadyInGame, "already connected to a game"),
+		},
+		{
+			description: "Clean Create rainbow - OK",
+			action: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{Variant: model.VariantRainbow, HandSize: 3},
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{Variant: model.VariantRainbow, HandSize: 3},
+			},
+			expectedError: nil,
+		},
+		{
+			description: "Clean Create - Fail: unknown variant",
+			action: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{Variant: "black"},
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{Variant: "black"},
+			},
+			expectedError: model.NewError(model.ErrInvalidOptions, "unknown variant: black"),
+		},
+		{
+			description: "Clean Create - Fail: unknown timeout",
+			action: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{TurnSeconds: 30, OnTimeout: "pass"},
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{TurnSeconds: 30, OnTimeout: "pass"},
+			},
+			expectedError: model.NewError(model.ErrInvalidOptions, "on timeout must be discard or end"),
+		},
+		{
+			description: "Clean Create - Fail: hand size too large",
+			action: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{HandSize: 7},
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{HandSize: 7},
+			},
+			expectedError: model.NewError(model.ErrInvalidOptions, "hand size must be between 1 and 6"),
+		},
+		{
+			description: "Clean Create - Fail: full final round with one card",
+			action: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{HandSize: 1, FullFinalRound: true},
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{HandSize: 1, FullFinalRound: true},
+			},
+			expectedError: model.NewError(model.ErrInvalidOptions, "a full final round needs a hand size of at least 2"),
+		},
+		{
+			description: "Clean Create - Fail: min players exceeds max players",
+			action: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{MinPlayers: 4, MaxPlayers: 3},
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionCreate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				Options:      &model.GameOptions{MinPlayers: 4, MaxPlayers: 3},
+			},
+			expectedError: model.NewError(model.ErrInvalidOptions, "players must be between 2 and 6, and min players may not exceed max players"),
 		},
 		//JOIN
 		{
@@ -170,6 +322,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "Dirty",
 				Card:         []int{0, 1, 2},
 				Clue:         "Dirty",
+				Token:        "Session Token",
 			},
 			state: nil,
 			expectedAction: model.Action{
@@ -179,6 +332,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "",
 				Card:         nil,
 				Clue:         "",
+				Token:        "Session Token",
 			},
 			expectedError: nil,
 		},
@@ -201,7 +355,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Card:         nil,
 				Clue:         "",
 			},
-			expectedError: fmt.Errorf("already connected to a game"),
+			expectedError: model.NewError(model.ErrAlreadyInGame, "already connected to a game"),
 		},
 		{
 			description: "Clean Join - Fail: missing game id",
@@ -222,9 +376,264 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Card:         nil,
 				Clue:         "",
 			},
-			expectedError: fmt.Errorf("join action must have game id"),
+			expectedError: model.NewError(model.ErrInvalidAction, "join action must have game id"),
 		},
 		//START
+		//SPECTATE
+		{
+			description: "Dirty Spectate - OK",
+			action: model.Action{
+				Type:         model.ActionSpectate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+				TargetPlayer: "Dirty",
+				Card:         []int{0},
+				Clue:         "Dirty",
+				Token:        "Dirty",
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionSpectate,
+				GameID:       "My Game",
+				ActivePlayer: "Active Player",
+			},
+			expectedError: nil,
+		},
+		{
+			description: "Clean Spectate - Fail: already connected",
+			action: model.Action{
+				Type:   model.ActionSpectate,
+				GameID: "My Game",
+			},
+			state: &model.GameState{},
+			expectedAction: model.Action{
+				Type:   model.ActionSpectate,
+				GameID: "My Game",
+			},
+			expectedError: model.NewError(model.ErrAlreadyInGame, "already connected to a game"),
+		},
+		{
+			description: "Clean Spectate - Fail: missing game id",
+			action: model.Action{
+				Type: model.ActionSpectate,
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type: model.ActionSpectate,
+			},
+			expectedError: model.NewError(model.ErrInvalidAction, "spectate action must have game id"),
+		},
+		{
+			description: "Dirty List Games - OK",
+			action: model.Action{
+				Type:         model.ActionListGames,
+				ActivePlayer: "Me",
+				GameID:       "Dirty",
+				TargetPlayer: "Dirty",
+				Card:         []int{1},
+				Clue:         "Dirty",
+				Token:        "Dirty",
+				Seed:         seedOf(1),
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionListGames,
+				ActivePlayer: "Me",
+			},
+			expectedError: nil,
+		},
+		{
+			description: "Clean Subscribe Lobby - Fail: already connected",
+			action: model.Action{
+				Type:         model.ActionSubscribeLobby,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{},
+			expectedAction: model.Action{
+				Type:         model.ActionSubscribeLobby,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrAlreadyInGame, "already connected to a game"),
+		},
+		{
+			description: "Dirty Chat - OK: not your turn",
+			action: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+				Message:      "hello",
+				GameID:       "Dirty",
+				TargetPlayer: "Dirty",
+				Card:         []int{1},
+				Clue:         "Dirty",
+				Token:        "Dirty",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "You"}, {Id: "Me"}},
+				Started: true,
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+				Message:      "hello",
+			},
+			expectedError: nil,
+		},
+		{
+			description: "Clean Chat - Fail: no game",
+			action: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+				Message:      "hello",
+			},
+			state: nil,
+			expectedAction: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+				Message:      "hello",
+			},
+			expectedError: model.NewError(model.ErrNotInGame, "not connected to a game"),
+		},
+		{
+			description: "Clean Chat - Fail: no message",
+			action: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{},
+			expectedAction: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrInvalidAction, "chat action must have a message"),
+		},
+		{
+			description: "Clean Chat - Fail: message too long",
+			action: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+				Message:      strings.Repeat("å", 201),
+			},
+			state: &model.GameState{},
+			expectedAction: model.Action{
+				Type:         model.ActionChat,
+				ActivePlayer: "Me",
+				Message:      strings.Repeat("å", 201),
+			},
+			expectedError: model.NewError(model.ErrInvalidAction, "chat message may not be longer than 200 characters"),
+		},
+		{
+			description: "Dirty Play - OK: message removed",
+			action: model.Action{
+				Type:         model.ActionPlay,
+				ActivePlayer: "Me",
+				Card:         []int{0},
+				Message:      "Dirty",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me", Cards: []model.Card{b1}}, {Id: "You"}},
+				Started: true,
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionPlay,
+				ActivePlayer: "Me",
+				Card:         []int{0},
+			},
+			expectedError: nil,
+		},
+		{
+			description: "Dirty Add Bot - OK",
+			action: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+				TargetPlayer: "Robot",
+				GameID:       "Dirty",
+				Card:         []int{1},
+				Clue:         "Dirty",
+				Token:        "Dirty",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me"}},
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+				TargetPlayer: "Robot",
+			},
+			expectedError: nil,
+		},
+		{
+			description: "Clean Add Bot - Fail: not first player",
+			action: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "You"}, {Id: "Me"}},
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrNotCreator, "only creator may add bots"),
+		},
+		{
+			description: "Clean Add Bot - Fail: game already started",
+			action: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me"}, {Id: "You"}},
+				Started: true,
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrGameStarted, "game already started"),
+		},
+		{
+			description: "Clean Add Bot - Fail: no players",
+			action: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{},
+			expectedAction: model.Action{
+				Type:         model.ActionAddBot,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrNotInGame, "not connected to a game"),
+		},
+		{
+			description: "Clean Start - Fail: no players",
+			action: model.Action{
+				Type:         model.ActionStart,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{},
+			expectedAction: model.Action{
+				Type:         model.ActionStart,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrNotInGame, "not connected to a game"),
+		},
+		{
+			description: "Clean Start - Fail: game has ended",
+			action: model.Action{
+				Type:         model.ActionStart,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me"}, {Id: "You"}},
+				Ended:   true,
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionStart,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrNotInGame, "game has ended"),
+		},
 		{
 			description: "Clean Start - OK",
 			action: model.Action{
@@ -249,6 +658,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Card:         nil,
 				Clue:         "Dirty",
 				TargetPlayer: "Dirty",
+				Token:        "Dirty",
 			},
 			state: &model.GameState{
 				Players: []model.Player{{Id: "Me"}, {Id: "You"}},
@@ -270,7 +680,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Type:         model.ActionStart,
 				ActivePlayer: "Me",
 			},
-			expectedError: fmt.Errorf("not connected to a game"),
+			expectedError: model.NewError(model.ErrNotInGame, "not connected to a game"),
 		},
 		{
 			description: "Clean Start - Fail: game already started",
@@ -286,7 +696,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Type:         model.ActionStart,
 				ActivePlayer: "Me",
 			},
-			expectedError: fmt.Errorf("game already started"),
+			expectedError: model.NewError(model.ErrGameStarted, "game already started"),
 		},
 		{
 			description: "Clean Start - Fail: not first player",
@@ -301,7 +711,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Type:         model.ActionStart,
 				ActivePlayer: "Me",
 			},
-			expectedError: fmt.Errorf("only creator may start game"),
+			expectedError: model.NewError(model.ErrNotCreator, "only creator may start game"),
 		},
 		{
 			description: "Clean Start - Fail: too few players",
@@ -316,7 +726,23 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Type:         model.ActionStart,
 				ActivePlayer: "Me",
 			},
-			expectedError: fmt.Errorf("too few players"),
+			expectedError: model.NewError(model.ErrTooFewPlayers, "too few players"),
+		},
+		{
+			description: "Clean Start - Fail: fewer than min players",
+			action: model.Action{
+				Type:         model.ActionStart,
+				ActivePlayer: "Me",
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me"}, {Id: "You"}},
+				Options: model.GameOptions{MinPlayers: 3},
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionStart,
+				ActivePlayer: "Me",
+			},
+			expectedError: model.NewError(model.ErrTooFewPlayers, "too few players"),
 		},
 		//CLUE
 		{
@@ -372,6 +798,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				Clue:         "W",
 				Card:         []int{1, 2, 3, 4, 5},
 				GameID:       "Play first card!!",
+				Token:        "Dirty",
 			},
 			state: &model.GameState{
 				Players: []model.Player{{Id: "Me"}, {Id: "You"}},
@@ -401,7 +828,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "You",
 				Clue:         "B",
 			},
-			expectedError: fmt.Errorf("not connected to a game"),
+			expectedError: model.NewError(model.ErrNotInGame, "not connected to a game"),
 		},
 		{
 			description: "Clean Clue Blue - Fail: game not started",
@@ -421,7 +848,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "You",
 				Clue:         "B",
 			},
-			expectedError: fmt.Errorf("game is not started"),
+			expectedError: model.NewError(model.ErrGameNotStarted, "game is not started"),
 		},
 		{
 			description: "Clean Clue Blue - Fail: not your turn",
@@ -441,7 +868,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "You",
 				Clue:         "B",
 			},
-			expectedError: fmt.Errorf("not your turn"),
+			expectedError: model.NewError(model.ErrNotYourTurn, "not your turn"),
 		},
 		{
 			description: "Clean Clue Purple - Fail: purple is not a valid color",
@@ -462,7 +889,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "You",
 				Clue:         "P",
 			},
-			expectedError: fmt.Errorf("clue action must have clue field that matches ^[12345BGRWY]$"),
+			expectedError: model.NewError(model.ErrInvalidAction, "clue action must have clue field that matches ^[12345BGRWY]$"),
 		},
 		{
 			description: "Clean Clue 6 - Fail: 6 is not a valid value",
@@ -483,7 +910,29 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "You",
 				Clue:         "6",
 			},
-			expectedError: fmt.Errorf("clue action must have clue field that matches ^[12345BGRWY]$"),
+			expectedError: model.NewError(model.ErrInvalidAction, "clue action must have clue field that matches ^[12345BGRWY]$"),
+		},
+		{
+			description: "Clean Clue Rainbow - Fail: rainbow is not a valid color",
+			action: model.Action{
+				Type:         model.ActionClue,
+				ActivePlayer: "Me",
+				TargetPlayer: "You",
+				Clue:         model.ColorRainbow,
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me"}, {Id: "You"}},
+				Started: true,
+				Clues:   8,
+				Options: model.GameOptions{Variant: model.VariantRainbow},
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionClue,
+				ActivePlayer: "Me",
+				TargetPlayer: "You",
+				Clue:         model.ColorRainbow,
+			},
+			expectedError: model.NewError(model.ErrInvalidAction, "clue action must have clue field that matches ^[12345BGRWY]$"),
 		},
 		{
 			description: "Clean Clue Blue - Fail: target player not in game",
@@ -504,7 +953,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "Them",
 				Clue:         "B",
 			},
-			expectedError: fmt.Errorf("player Them is not in this game"),
+			expectedError: model.NewError(model.ErrPlayerNotFound, "player Them is not in this game"),
 		},
 		{
 			description: "Clean Clue Blue - Fail: can't give clues to self",
@@ -525,7 +974,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "Me",
 				Clue:         "B",
 			},
-			expectedError: fmt.Errorf("you may not target yourself"),
+			expectedError: model.NewError(model.ErrInvalidAction, "you may not target yourself"),
 		},
 		{
 			description: "Clean Clue Blue - fail: no clues left",
@@ -546,7 +995,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "You",
 				Clue:         "B",
 			},
-			expectedError: fmt.Errorf("there are no clues available to give"),
+			expectedError: model.NewError(model.ErrNoClues, "there are no clues available to give"),
 		},
 		//PLAY
 		{
@@ -594,6 +1043,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "Dirty",
 				GameID:       "Dirty",
 				Clue:         "Dirty",
+				Token:        "Dirty",
 			},
 			state: &model.GameState{
 				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
@@ -619,7 +1069,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{0},
 			},
-			expectedError: fmt.Errorf("not connected to a game"),
+			expectedError: model.NewError(model.ErrNotInGame, "not connected to a game"),
 		},
 		{
 			description: "Clean Play first card - Fail: not your turn",
@@ -637,7 +1087,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{0},
 			},
-			expectedError: fmt.Errorf("not your turn"),
+			expectedError: model.NewError(model.ErrNotYourTurn, "not your turn"),
 		},
 		{
 			description: "Clean Play first card - Fail: not started",
@@ -648,21 +1098,57 @@ func TestValidateAndCleanAction(t *testing.T) {
 			},
 			state: &model.GameState{
 				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
-				Started: false,
+				Started: false,
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionPlay,
+				ActivePlayer: "Me",
+				Card:         []int{0},
+			},
+			expectedError: model.NewError(model.ErrGameNotStarted, "game is not started"),
+		},
+		{
+			description: "Clean Play first card - Fail: play 0 cards",
+			action: model.Action{
+				Type:         model.ActionPlay,
+				ActivePlayer: "Me",
+				Card:         []int{},
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
+				Started: true,
+			},
+			expectedAction: model.Action{
+				Type:         model.ActionPlay,
+				ActivePlayer: "Me",
+				Card:         []int{},
+			},
+			expectedError: model.NewError(model.ErrInvalidAction, "exactly 1 card must be played. Not 0"),
+		},
+		{
+			description: "Clean Play first card - Fail: play 2 cards",
+			action: model.Action{
+				Type:         model.ActionPlay,
+				ActivePlayer: "Me",
+				Card:         []int{2, 3},
+			},
+			state: &model.GameState{
+				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
+				Started: true,
 			},
 			expectedAction: model.Action{
 				Type:         model.ActionPlay,
 				ActivePlayer: "Me",
-				Card:         []int{0},
+				Card:         []int{2, 3},
 			},
-			expectedError: fmt.Errorf("game is not started"),
+			expectedError: model.NewError(model.ErrInvalidAction, "exactly 1 card must be played. Not 2"),
 		},
 		{
-			description: "Clean Play first card - Fail: play 0 cards",
+			description: "Clean Play first card - Fail: incorrect index",
 			action: model.Action{
 				Type:         model.ActionPlay,
 				ActivePlayer: "Me",
-				Card:         []int{},
+				Card:         []int{5},
 			},
 			state: &model.GameState{
 				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
@@ -671,45 +1157,47 @@ func TestValidateAndCleanAction(t *testing.T) {
 			expectedAction: model.Action{
 				Type:         model.ActionPlay,
 				ActivePlayer: "Me",
-				Card:         []int{},
+				Card:         []int{5},
 			},
-			expectedError: fmt.Errorf("exactly 1 card must be played. Not 0"),
+			expectedError: model.NewError(model.ErrInvalidAction, "no card on index 5"),
 		},
 		{
-			description: "Clean Play first card - Fail: play 2 cards",
+			description: "Clean Play first card - Fail: game has ended",
 			action: model.Action{
 				Type:         model.ActionPlay,
 				ActivePlayer: "Me",
-				Card:         []int{2, 3},
+				Card:         []int{0},
 			},
 			state: &model.GameState{
-				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
+				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w2, w3}}, {Id: "You"}},
 				Started: true,
+				Ended:   true,
 			},
 			expectedAction: model.Action{
 				Type:         model.ActionPlay,
 				ActivePlayer: "Me",
-				Card:         []int{2, 3},
+				Card:         []int{0},
 			},
-			expectedError: fmt.Errorf("exactly 1 card must be played. Not 2"),
+			expectedError: model.NewError(model.ErrNotInGame, "game has ended"),
 		},
 		{
-			description: "Clean Play first card - Fail: incorrect index",
+			description: "Clean Play first card - Fail: hand has shrunk",
 			action: model.Action{
 				Type:         model.ActionPlay,
 				ActivePlayer: "Me",
-				Card:         []int{5},
+				Card:         []int{3},
 			},
 			state: &model.GameState{
-				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
-				Started: true,
+				Players:        []model.Player{{Id: "Me", Cards: []model.Card{w1, w2, w3}}, {Id: "You"}},
+				TurnsRemaining: 1,
+				Started:        true,
 			},
 			expectedAction: model.Action{
 				Type:         model.ActionPlay,
 				ActivePlayer: "Me",
-				Card:         []int{5},
+				Card:         []int{3},
 			},
-			expectedError: fmt.Errorf("no card on index 5"),
+			expectedError: model.NewError(model.ErrInvalidAction, "no card on index 3"),
 		},
 		//DISCARD
 		{
@@ -757,6 +1245,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				TargetPlayer: "Dirty",
 				GameID:       "Dirty",
 				Clue:         "Dirty",
+				Token:        "Dirty",
 			},
 			state: &model.GameState{
 				Players: []model.Player{{Id: "Me", Cards: []model.Card{w1, w1, w2, w2, w3}}, {Id: "You"}},
@@ -782,7 +1271,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{0},
 			},
-			expectedError: fmt.Errorf("not connected to a game"),
+			expectedError: model.NewError(model.ErrNotInGame, "not connected to a game"),
 		},
 		{
 			description: "Clean Discard first card - Fail: not your turn",
@@ -800,7 +1289,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{0},
 			},
-			expectedError: fmt.Errorf("not your turn"),
+			expectedError: model.NewError(model.ErrNotYourTurn, "not your turn"),
 		},
 		{
 			description: "Clean Discard first card - Fail: not started",
@@ -818,7 +1307,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{0},
 			},
-			expectedError: fmt.Errorf("game is not started"),
+			expectedError: model.NewError(model.ErrGameNotStarted, "game is not started"),
 		},
 		{
 			description: "Clean Discard first card - Fail: Discard 0 cards",
@@ -836,7 +1325,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{},
 			},
-			expectedError: fmt.Errorf("exactly 1 card must be discarded. Not 0"),
+			expectedError: model.NewError(model.ErrInvalidAction, "exactly 1 card must be discarded. Not 0"),
 		},
 		{
 			description: "Clean Discard first card - Fail: Discard 2 cards",
@@ -854,7 +1343,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{2, 3},
 			},
-			expectedError: fmt.Errorf("exactly 1 card must be discarded. Not 2"),
+			expectedError: model.NewError(model.ErrInvalidAction, "exactly 1 card must be discarded. Not 2"),
 		},
 		{
 			description: "Clean Discard first card - Fail: incorrect index",
@@ -872,7 +1361,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 				ActivePlayer: "Me",
 				Card:         []int{5},
 			},
-			expectedError: fmt.Errorf("no card on index 5"),
+			expectedError: model.NewError(model.ErrInvalidAction, "no card on index 5"),
 		},
 		{
 			description: "Unknown action - Fail",
@@ -882,7 +1371,7 @@ func TestValidateAndCleanAction(t *testing.T) {
 			expectedAction: model.Action{
 				Type: "unknown action",
 			},
-			expectedError: fmt.Errorf("unknown action: unknown action"),
+			expectedError: model.NewError(model.ErrUnknownAction, "unknown action: unknown action"),
 		},
 	}
 
@@ -911,6 +1400,18 @@ func TestHandleAction(t *testing.T) {
 			state:         model.GameState{},
 			expectedState: model.GameState{PlayedAction: model.Action{Type: model.ActionPing}},
 		},
+		//CREATE
+		{
+			description: "Create rainbow game",
+			action:      model.Action{Type: model.ActionCreate, ActivePlayer: "Up", Options: &model.GameOptions{Variant: model.VariantRainbow, MaxLives: 1}, Seed: seedOf(42)},
+			state:       model.GameState{},
+			expectedState: model.GameState{
+				Players:      []model.Player{{Id: "Up"}},
+				Options:      model.GameOptions{Variant: model.VariantRainbow, MaxLives: 1, MaxClues: 8, MinPlayers: 2, MaxPlayers: 5},
+				Seed:         seedOf(42), // kept out of the played action
+				PlayedAction: model.Action{Type: model.ActionCreate, ActivePlayer: "Up", Options: &model.GameOptions{Variant: model.VariantRainbow, MaxLives: 1}},
+			},
+		},
 		//JOIN
 		{
 			description: "Join empty game",
@@ -930,6 +1431,15 @@ func TestHandleAction(t *testing.T) {
 				PlayedAction: model.Action{Type: model.ActionJoin, ActivePlayer: "Up"},
 			},
 		},
+		{
+			description: "Re-Join game - not added again",
+			action:      model.Action{Type: model.ActionJoin, ActivePlayer: "Down"},
+			state:       model.GameState{Players: []model.Player{{Id: "Down"}, {Id: "Strange"}}},
+			expectedState: model.GameState{
+				Players:      []model.Player{{Id: "Down"}, {Id: "Strange"}},
+				PlayedAction: model.Action{Type: model.ActionJoin, ActivePlayer: "Down"},
+			},
+		},
 		{
 			description: "Join 5 player game - fail",
 			action:      model.Action{Type: model.ActionJoin, ActivePlayer: "Up"},
@@ -939,6 +1449,16 @@ func TestHandleAction(t *testing.T) {
 				PlayedAction: model.Action{Type: model.ActionJoin, ActivePlayer: "Up"},
 			},
 		},
+		{
+			description: "Join full 2 player game - fail",
+			action:      model.Action{Type: model.ActionJoin, ActivePlayer: "Up"},
+			state:       model.GameState{Players: []model.Player{{Id: "Down"}, {Id: "Strange"}}, Options: model.GameOptions{MaxPlayers: 2}},
+			expectedState: model.GameState{
+				Players:      []model.Player{{Id: "Down"}, {Id: "Strange"}},
+				Options:      model.GameOptions{MaxPlayers: 2},
+				PlayedAction: model.Action{Type: model.ActionJoin, ActivePlayer: "Up"},
+			},
+		},
 		{
 			description: "Join started game - fail",
 			action:      model.Action{Type: model.ActionJoin, ActivePlayer: "Up"},
@@ -949,6 +1469,15 @@ func TestHandleAction(t *testing.T) {
 				PlayedAction: model.Action{Type: model.ActionJoin, ActivePlayer: "Up"},
 			},
 		},
+		{
+			description: "Add bot",
+			action:      model.Action{Type: model.ActionAddBot, ActivePlayer: "Up", TargetPlayer: "Robot"},
+			state:       model.GameState{Players: []model.Player{{Id: "Up"}}},
+			expectedState: model.GameState{
+				Players:      []model.Player{{Id: "Up"}, {Id: "Robot"}},
+				PlayedAction: model.Action{Type: model.ActionAddBot, ActivePlayer: "Up", TargetPlayer: "Robot"},
+			},
+		},
 		//START
 		{
 			description: "Start 2 player game",
@@ -957,8 +1486,8 @@ func TestHandleAction(t *testing.T) {
 			deck:        []model.Card{w1, w2, w3, w4, w5, r1, r2, r3, r4, r5, b1, b2, b3, b4, b5},
 			expectedState: model.GameState{
 				Players: []model.Player{
-					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}}, //dealt 5 cards
-					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}}, //dealt 5 cards
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
 				},
 				Started:      true,
 				Deck:         5,
@@ -975,10 +1504,10 @@ func TestHandleAction(t *testing.T) {
 			deck:        []model.Card{w1, w2, w3, w4, r1, r2, r3, r4, b1, b2, b3, b4, g1, g2, g3, g4},
 			expectedState: model.GameState{
 				Players: []model.Player{
-					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4}}, //dealt 4 cards
-					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4}},
-					{Id: "Strange", Cards: []model.Card{b1, b2, b3, b4}},
-					{Id: "Charm", Cards: []model.Card{g1, g2, g3, g4}},
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}}}, //dealt 4 cards
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}}},
+					{Id: "Strange", Cards: []model.Card{b1, b2, b3, b4}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}}},
+					{Id: "Charm", Cards: []model.Card{g1, g2, g3, g4}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}}},
 				},
 				Started:      true,
 				Deck:         0,
@@ -988,6 +1517,28 @@ func TestHandleAction(t *testing.T) {
 			},
 			expectedDeck: []model.Card{},
 		},
+		{
+			description: "Start 2 player game with house rules",
+			action:      model.Action{Type: model.ActionStart, ActivePlayer: "Up"},
+			state: model.GameState{
+				Players: []model.Player{{Id: "Up"}, {Id: "Down"}},
+				Options: model.GameOptions{HandSize: 3, MaxClues: 4, MaxLives: 1},
+			},
+			deck: []model.Card{w1, w2, w3, r1, r2, r3, b1, b2, b3},
+			expectedState: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2, w3}, Knowledge: []model.CardKnowledge{{}, {}, {}}}, //dealt 3 cards
+					{Id: "Down", Cards: []model.Card{r1, r2, r3}, Knowledge: []model.CardKnowledge{{}, {}, {}}},
+				},
+				Options:      model.GameOptions{HandSize: 3, MaxClues: 4, MaxLives: 1},
+				Started:      true,
+				Deck:         3,
+				Clues:        4,
+				Lives:        1,
+				PlayedAction: model.Action{Type: model.ActionStart, ActivePlayer: "Up"},
+			},
+			expectedDeck: []model.Card{b1, b2, b3},
+		},
 		//CLUE
 		{
 			description: "Clue red match all",
@@ -1003,7 +1554,9 @@ func TestHandleAction(t *testing.T) {
 			deck: []model.Card{b1, b2, b3, b4, b5},
 			expectedState: model.GameState{
 				Players: []model.Player{
-					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}, Knowledge: []model.CardKnowledge{
+						{Colors: []string{"R"}}, {Colors: []string{"R"}}, {Colors: []string{"R"}}, {Colors: []string{"R"}}, {Colors: []string{"R"}},
+					}},
 					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
 				},
 				Clues: 7, //reduced by one
@@ -1015,6 +1568,7 @@ func TestHandleAction(t *testing.T) {
 					Card:         []int{0, 1, 2, 3, 4}, //cards added to action
 				},
 				Deck: 5,
+				Turn: 1,
 			},
 			expectedDeck: []model.Card{b1, b2, b3, b4, b5},
 		},
@@ -1031,11 +1585,14 @@ func TestHandleAction(t *testing.T) {
 			deck: []model.Card{b1, b2, b3, b4, b5},
 			expectedState: model.GameState{
 				Players: []model.Player{
-					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}, Knowledge: []model.CardKnowledge{
+						{NotColors: []string{"W"}}, {NotColors: []string{"W"}}, {NotColors: []string{"W"}}, {NotColors: []string{"W"}}, {NotColors: []string{"W"}},
+					}},
 					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
 				},
 				Clues: 7, //reduced by one
 				Deck:  5,
+				Turn:  1,
 				PlayedAction: model.Action{
 					Type:         model.ActionClue,
 					ActivePlayer: "Up",
@@ -1046,6 +1603,40 @@ func TestHandleAction(t *testing.T) {
 			},
 			expectedDeck: []model.Card{b1, b2, b3, b4, b5},
 		},
+		{
+			description: "Clue red match rainbow",
+			action:      model.Action{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "R"},
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
+					{Id: "Down", Cards: []model.Card{m1, r2, b3, m5, g1}},
+				},
+				Clues:   8,
+				Deck:    5,
+				Options: model.GameOptions{Variant: model.VariantRainbow},
+			},
+			deck: []model.Card{b1, b2, b3, b4, b5},
+			expectedState: model.GameState{
+				Players: []model.Player{
+					{Id: "Down", Cards: []model.Card{m1, r2, b3, m5, g1}, Knowledge: []model.CardKnowledge{
+						{Colors: []string{"R"}}, {Colors: []string{"R"}}, {NotColors: []string{"R"}}, {Colors: []string{"R"}}, {NotColors: []string{"R"}},
+					}},
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
+				},
+				Clues:   7,
+				Deck:    5,
+				Turn:    1,
+				Options: model.GameOptions{Variant: model.VariantRainbow},
+				PlayedAction: model.Action{
+					Type:         model.ActionClue,
+					ActivePlayer: "Up",
+					TargetPlayer: "Down",
+					Clue:         "R",
+					Card:         []int{0, 1, 3},
+				},
+			},
+			expectedDeck: []model.Card{b1, b2, b3, b4, b5},
+		},
 		{
 			description: "Clue 3 match index 2",
 			action:      model.Action{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "3"},
@@ -1059,11 +1650,14 @@ func TestHandleAction(t *testing.T) {
 			deck: []model.Card{b1, b2, b3, b4, b5},
 			expectedState: model.GameState{
 				Players: []model.Player{
-					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}, Knowledge: []model.CardKnowledge{
+						{NotValues: []string{"3"}}, {NotValues: []string{"3"}}, {Values: []string{"3"}}, {NotValues: []string{"3"}}, {NotValues: []string{"3"}},
+					}},
 					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
 				},
 				Clues: 7, //reduced by one
 				Deck:  5,
+				Turn:  1,
 				PlayedAction: model.Action{
 					Type:         model.ActionClue,
 					ActivePlayer: "Up",
@@ -1074,7 +1668,102 @@ func TestHandleAction(t *testing.T) {
 			},
 			expectedDeck: []model.Card{b1, b2, b3, b4, b5},
 		},
+		{
+			description: "Clue 1 adds to knowledge",
+			action:      model.Action{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "1"},
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
+					{Id: "Down", Cards: []model.Card{r1, b1, r3}, Knowledge: []model.CardKnowledge{
+						{Colors: []string{"R"}, NotValues: []string{"3"}}, {NotColors: []string{"R"}}, {Colors: []string{"R"}, Values: []string{"3"}},
+					}},
+				},
+				Clues: 8,
+				Deck:  5,
+			},
+			deck: []model.Card{b1, b2, b3, b4, b5},
+			expectedState: model.GameState{
+				Players: []model.Player{
+					{Id: "Down", Cards: []model.Card{r1, b1, r3}, Knowledge: []model.CardKnowledge{
+						{Colors: []string{"R"}, Values: []string{"1"}, NotValues: []string{"3"}},
+						{Values: []string{"1"}, NotColors: []string{"R"}},
+						{Colors: []string{"R"}, Values: []string{"3"}, NotValues: []string{"1"}},
+					}},
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
+				},
+				Clues: 7,
+				Deck:  5,
+				Turn:  1,
+				PlayedAction: model.Action{
+					Type:         model.ActionClue,
+					ActivePlayer: "Up",
+					TargetPlayer: "Down",
+					Clue:         "1",
+					Card:         []int{0, 1},
+				},
+			},
+			expectedDeck: []model.Card{b1, b2, b3, b4, b5},
+		},
+		{
+			description: "Clue 3 twice - not repeated in knowledge",
+			action:      model.Action{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "3"},
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
+					{Id: "Down", Cards: []model.Card{r1, r3}, Knowledge: []model.CardKnowledge{{NotValues: []string{"3"}}, {Values: []string{"3"}}}},
+				},
+				Clues: 8,
+				Deck:  5,
+			},
+			deck: []model.Card{b1, b2, b3, b4, b5},
+			expectedState: model.GameState{
+				Players: []model.Player{
+					{Id: "Down", Cards: []model.Card{r1, r3}, Knowledge: []model.CardKnowledge{{NotValues: []string{"3"}}, {Values: []string{"3"}}}},
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
+				},
+				Clues: 7,
+				Deck:  5,
+				Turn:  1,
+				PlayedAction: model.Action{
+					Type:         model.ActionClue,
+					ActivePlayer: "Up",
+					TargetPlayer: "Down",
+					Clue:         "3",
+					Card:         []int{1},
+				},
+			},
+			expectedDeck: []model.Card{b1, b2, b3, b4, b5},
+		},
 		//PLAY
+		{
+			description: "Play W1 - ok: knowledge of drawn card is empty",
+			action:      model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Down", Cards: []model.Card{r1, r2}},
+				},
+				Deck:  5,
+				Lives: 3,
+			},
+			deck: []model.Card{b1, b2, b3, b4, b5},
+			expectedState: model.GameState{
+				Players: []model.Player{
+					{Id: "Down", Cards: []model.Card{r1, r2}},
+					{Id: "Up", Cards: []model.Card{b1, w2}, Knowledge: []model.CardKnowledge{{}, {NotValues: []string{"1"}}}},
+				},
+				PlayedAction: model.Action{
+					Type:         model.ActionPlay,
+					ActivePlayer: "Up",
+					Card:         []int{0},
+				},
+				Deck:  4,
+				Turn:  1,
+				Lives: 3,
+				Table: []model.Card{w1},
+			},
+			expectedDeck: []model.Card{b2, b3, b4, b5},
+		},
 		{
 			description: "Play W1 - ok",
 			action:      model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
@@ -1098,6 +1787,7 @@ func TestHandleAction(t *testing.T) {
 					Card:         []int{0}, //cards added to action
 				},
 				Deck:  4, //reduced by one
+				Turn:  1,
 				Lives: 3,
 				Table: []model.Card{w1},
 			},
@@ -1123,6 +1813,7 @@ func TestHandleAction(t *testing.T) {
 				},
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
 				Deck:         4, //reduced by one
+				Turn:         1,
 				Lives:        2, //reduced by one
 				Table:        []model.Card{w1},
 				Discards:     []model.Card{w1}, // played card ends up in discard
@@ -1149,6 +1840,7 @@ func TestHandleAction(t *testing.T) {
 				},
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{1}},
 				Deck:         4, //reduced by one
+				Turn:         1,
 				Lives:        3,
 				Table:        []model.Card{w1, w2},
 			},
@@ -1174,6 +1866,7 @@ func TestHandleAction(t *testing.T) {
 				},
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{1}},
 				Deck:         4, //reduced by one
+				Turn:         1,
 				Lives:        2, //reduced by one
 				Table:        []model.Card{b1},
 				Discards:     []model.Card{w2},
@@ -1200,6 +1893,7 @@ func TestHandleAction(t *testing.T) {
 				},
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{4}},
 				Deck:         4, //reduced by one
+				Turn:         1,
 				Lives:        3,
 				Clues:        1, //increased by one
 				Table:        []model.Card{w1, w2, w3, w4, w5},
@@ -1227,6 +1921,7 @@ func TestHandleAction(t *testing.T) {
 				},
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{4}},
 				Deck:         4, //reduced by one
+				Turn:         1,
 				Lives:        3,
 				Clues:        8, //not changed
 				Table:        []model.Card{w1, w2, w3, w4, w5},
@@ -1259,6 +1954,7 @@ func TestHandleAction(t *testing.T) {
 				},
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{4}},
 				Deck:         4, //reduced by one
+				Turn:         1,
 				Lives:        3,
 				Clues:        1, //increased by one
 				Table: []model.Card{
@@ -1273,56 +1969,126 @@ func TestHandleAction(t *testing.T) {
 			expectedDeck: []model.Card{b2, b3, b4, b5}, // first card removed
 		},
 		{
-			description: "Play W1 empty deck",
+			description: "Play W1 - ok: last card drawn",
 			action:      model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
 			state: model.GameState{
 				Players: []model.Player{
 					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
 					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
 				},
-				Deck:  0,
-				Lives: 3,
+				Deck:    1,
+				Lives:   3,
+				Started: true,
+			},
+			deck: []model.Card{b1},
+			expectedState: model.GameState{
+				Players: []model.Player{
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
+					{Id: "Up", Cards: []model.Card{b1, w2, w3, w4, w5}},
+				},
+				PlayedAction:   model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+				Deck:           0,
+				Turn:           1,
+				TurnsRemaining: 2, // every player gets a final turn
+				Lives:          3,
+				Table:          []model.Card{w1},
+				Started:        true,
+			},
+			expectedDeck: []model.Card{},
+		},
+		{
+			description: "Play W1 - ok: last card drawn, full final round",
+			action:      model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4}},
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4}},
+					{Id: "Left", Cards: []model.Card{g1, g2, g3, g4}},
+				},
+				Deck:    1,
+				Lives:   3,
+				Turn:    3, // Up has the first seat, and the turn of the second round
+				Options: model.GameOptions{FullFinalRound: true},
+				Started: true,
+			},
+			deck: []model.Card{b1},
+			expectedState: model.GameState{
+				Players: []model.Player{
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4}},
+					{Id: "Left", Cards: []model.Card{g1, g2, g3, g4}},
+					{Id: "Up", Cards: []model.Card{b1, w2, w3, w4}},
+				},
+				PlayedAction:   model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+				Deck:           0,
+				Turn:           4,
+				TurnsRemaining: 5, // the rest of the second round, and a final round
+				Lives:          3,
+				Table:          []model.Card{w1},
+				Options:        model.GameOptions{FullFinalRound: true},
+				Started:        true,
+			},
+			expectedDeck: []model.Card{},
+		},
+		{
+			description: "Play W1 empty deck - hand shrinks",
+			action:      model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}}, {}, {}, {}, {Values: []string{"5"}}}},
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
+				},
+				Deck:           0,
+				TurnsRemaining: 2,
+				Lives:          3,
+				Started:        true,
 			},
 			expectedState: model.GameState{
 				Players: []model.Player{
 					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
-					{Id: "Up", Cards: []model.Card{noCard, w2, w3, w4, w5}}, // index 0: w1 -> nil
+					{Id: "Up", Cards: []model.Card{w2, w3, w4, w5}, Knowledge: []model.CardKnowledge{{}, {}, {}, {Values: []string{"5"}}}}, // w1 is gone
 				},
 				PlayedAction: model.Action{
 					Type:         model.ActionPlay,
 					ActivePlayer: "Up",
 					Card:         []int{0}, //cards added to action
 				},
-				Deck:  -1, //reduced by one
-				Lives: 3,
-				Table: []model.Card{w1},
+				Deck:           0,
+				Turn:           1,
+				TurnsRemaining: 1, //reduced by one
+				Lives:          3,
+				Table:          []model.Card{w1},
+				Started:        true,
 			},
 		},
 		{
-			description: "Play last card",
+			description: "Play on the final turn",
 			action:      model.Action{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
 			state: model.GameState{
 				Players: []model.Player{
-					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}},
-					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
+					{Id: "Up", Cards: []model.Card{w1, w2, w3, w4}},
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4}},
 				},
-				Deck:  -1,
-				Lives: 3,
+				Deck:           0,
+				TurnsRemaining: 1,
+				Lives:          3,
+				Started:        true,
 			},
 			expectedState: model.GameState{
 				Players: []model.Player{
-					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}},
-					{Id: "Up", Cards: []model.Card{noCard, w2, w3, w4, w5}}, // index 0: w1 -> nil
+					{Id: "Down", Cards: []model.Card{r1, r2, r3, r4}},
+					{Id: "Up", Cards: []model.Card{w2, w3, w4}},
 				},
 				PlayedAction: model.Action{
 					Type:         model.ActionPlay,
 					ActivePlayer: "Up",
 					Card:         []int{0}, //cards added to action
 				},
-				Deck:  -2, //reduced by one
-				Lives: 3,
-				Table: []model.Card{w1},
-				Ended: true,
+				Deck:    0,
+				Turn:    1,
+				Lives:   3,
+				Table:   []model.Card{w1},
+				Started: true,
+				Ended:   true,
 			},
 		},
 		//DISCARD
@@ -1349,6 +2115,7 @@ func TestHandleAction(t *testing.T) {
 					Card:         []int{0}, //cards added to action
 				},
 				Deck:     4, //reduced by one
+				Turn:     1,
 				Lives:    3,
 				Clues:    1, //increased by one
 				Discards: []model.Card{w1},
@@ -1379,12 +2146,68 @@ func TestHandleAction(t *testing.T) {
 					Card:         []int{0}, //cards added to action
 				},
 				Deck:     4, //reduced by one
+				Turn:     1,
 				Lives:    3,
 				Clues:    8, //did not increase
 				Discards: []model.Card{w1},
 			},
 			expectedDeck: []model.Card{b2, b3, b4, b5}, // first card removed
 		},
+		//LEAVE
+		{
+			description: "Leave game that has not started",
+			action:      model.Action{Type: model.ActionLeave, ActivePlayer: "Up"},
+			state:       model.GameState{Players: []model.Player{{Id: "Up"}, {Id: "Down"}, {Id: "Left"}}},
+			expectedState: model.GameState{
+				Players:      []model.Player{{Id: "Down"}, {Id: "Left"}}, // Down creates from now on
+				PlayedAction: model.Action{Type: model.ActionLeave, ActivePlayer: "Up"},
+			},
+		},
+		{
+			description: "Leave game that has not started - last player",
+			action:      model.Action{Type: model.ActionLeave, ActivePlayer: "Up"},
+			state:       model.GameState{Players: []model.Player{{Id: "Up"}}},
+			expectedState: model.GameState{
+				Players:      []model.Player{},
+				PlayedAction: model.Action{Type: model.ActionLeave, ActivePlayer: "Up"},
+				Ended:        true,
+			},
+		},
+		{
+			description: "Leave started game - ends game",
+			action:      model.Action{Type: model.ActionLeave, ActivePlayer: "Down"},
+			state: model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2}},
+					{Id: "Down", Cards: []model.Card{r1, r2}},
+				},
+				Deck:    5,
+				Lives:   3,
+				Clues:   8,
+				Started: true,
+			},
+			deck: []model.Card{b1, b2, b3, b4, b5},
+			expectedState: model.GameState{
+				Players: []model.Player{ // not rotated, since leaving is not a turn
+					{Id: "Up", Cards: []model.Card{w1, w2}},
+					{Id: "Down", Cards: []model.Card{r1, r2}},
+				},
+				PlayedAction: model.Action{Type: model.ActionLeave, ActivePlayer: "Down"},
+				Deck:         5,
+				Lives:        3,
+				Clues:        8,
+				Started:      true,
+				Ended:        true,
+			},
+			expectedDeck: []model.Card{b1, b2, b3, b4, b5},
+		},
+		//ABANDON
+		{
+			description:   "Abandon game",
+			action:        model.Action{Type: model.ActionAbandon},
+			state:         model.GameState{Players: []model.Player{{Id: "Up"}}},
+			expectedState: model.GameState{Players: []model.Player{{Id: "Up"}}, PlayedAction: model.Action{Type: model.ActionAbandon}, Ended: true},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.description, func(t *testing.T) {
@@ -1407,9 +2230,9 @@ func TestHandleGameActions(t *testing.T) {
 	charmConn := &MockConn{BytesWritten: make(chan []byte)}
 	game := model.Game{
 		Id: "game",
-		Connections: map[model.PlayerID]net.Conn{
-			"Strange": strangeConn,
-			"Charm":   charmConn,
+		Connections: map[model.PlayerID]model.Connection{
+			"Strange": io.NewConnection(strangeConn),
+			"Charm":   io.NewConnection(charmConn),
 		},
 		Actions: actions,
 		State:   nil,
@@ -1430,9 +2253,11 @@ func TestHandleGameActions(t *testing.T) {
 				Discards:     []model.Card{},
 				Table:        []model.Card{},
 				Deck:         20,
+				Seq:          1,
 				PlayedAction: model.Action{Type: model.ActionJoin, ActivePlayer: "Strange"},
 				Started:      false,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1446,9 +2271,11 @@ func TestHandleGameActions(t *testing.T) {
 				Discards:     []model.Card{},
 				Table:        []model.Card{},
 				Deck:         20,
+				Seq:          2,
 				PlayedAction: model.Action{Type: model.ActionJoin, ActivePlayer: "Charm"},
 				Started:      false,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1457,17 +2284,19 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{b1, b1, b2, b3, b4}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+					{Id: "Charm", Cards: []model.Card{b1, b1, b2, b3, b4}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
 				},
 				Clues:        8,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{},
 				Deck:         10,
+				Seq:          3,
 				PlayedAction: model.Action{Type: model.ActionStart, ActivePlayer: "Strange"},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1476,17 +2305,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{b1, b1, b2, b3, b4}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{b1, b1, b2, b3, b4}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}}, {Values: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
 				},
 				Clues:        7,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{},
 				Deck:         10,
+				Turn:         1,
+				Seq:          4,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Strange", TargetPlayer: "Charm", Clue: "1", Card: []int{0, 1}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1495,17 +2327,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b2, b3, b4}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b2, b3, b4}, Knowledge: []model.CardKnowledge{{}, {Values: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}}},
 				},
 				Clues:        7,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1},
 				Deck:         9,
+				Turn:         2,
+				Seq:          5,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Charm", Card: []int{0}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1514,17 +2349,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b2, b3, b4}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b2, b3, b4}, Knowledge: []model.CardKnowledge{{NotValues: []string{"2"}}, {Values: []string{"1"}, NotValues: []string{"2"}}, {Values: []string{"2"}, NotValues: []string{"1"}}, {NotValues: []string{"1", "2"}}, {NotValues: []string{"1", "2"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
 				},
 				Clues:        6,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1},
 				Deck:         9,
+				Turn:         3,
+				Seq:          6,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Strange", TargetPlayer: "Charm", Clue: "2", Card: []int{2}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1533,17 +2371,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, b3, b4}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, b3, b4}, Knowledge: []model.CardKnowledge{{NotValues: []string{"2"}}, {Values: []string{"1"}, NotValues: []string{"2"}}, {}, {NotValues: []string{"1", "2"}}, {NotValues: []string{"1", "2"}}}},
 				},
 				Clues:        6,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2},
 				Deck:         8,
+				Turn:         4,
+				Seq:          7,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Charm", Card: []int{2}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1552,17 +2393,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, b3, b4}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, b3, b4}, Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {Values: []string{"1"}, NotValues: []string{"2", "3"}}, {NotValues: []string{"3"}}, {Values: []string{"3"}, NotValues: []string{"1", "2"}}, {NotValues: []string{"1", "2", "3"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
 				},
 				Clues:        5,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2},
 				Deck:         8,
+				Turn:         5,
+				Seq:          8,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Strange", TargetPlayer: "Charm", Clue: "3", Card: []int{3}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1571,17 +2415,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b4}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b4}, Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {Values: []string{"1"}, NotValues: []string{"2", "3"}}, {NotValues: []string{"3"}}, {}, {NotValues: []string{"1", "2", "3"}}}},
 				},
 				Clues:        5,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3},
 				Deck:         7,
+				Turn:         6,
+				Seq:          9,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Charm", Card: []int{3}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1590,17 +2437,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b4}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b4}, Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {Values: []string{"1"}, NotValues: []string{"2", "3"}}, {NotValues: []string{"3"}}, {}, {NotValues: []string{"1", "2", "3"}}}},
 				},
 				Clues:        5,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3},
 				Deck:         7,
+				Turn:         6,
+				Seq:          9,
 				PlayedAction: model.Action{Type: model.ActionPing, ActivePlayer: "Strange"},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1609,17 +2459,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b4}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b4}, Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {NotValues: []string{"3", "4"}}, {NotValues: []string{"4"}}, {Values: []string{"4"}, NotValues: []string{"1", "2", "3"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
 				},
 				Clues:        4,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3},
 				Deck:         7,
+				Turn:         7,
+				Seq:          10,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Strange", TargetPlayer: "Charm", Clue: "4", Card: []int{4}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1628,17 +2481,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {NotValues: []string{"3", "4"}}, {NotValues: []string{"4"}}, {}}},
 				},
 				Clues:        4,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4},
 				Deck:         6,
+				Turn:         8,
+				Seq:          11,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Charm", Card: []int{4}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1647,17 +2503,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
 				},
 				Clues:        3,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4},
 				Deck:         6,
+				Turn:         9,
+				Seq:          12,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Strange", TargetPlayer: "Charm", Clue: "1", Card: []int{0, 1, 2}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1666,17 +2525,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{Values: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
 				},
 				Clues:        2,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4},
 				Deck:         6,
+				Turn:         10,
+				Seq:          13,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Charm", TargetPlayer: "Strange", Clue: "1", Card: []int{0}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1685,17 +2547,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}, {NotValues: []string{"1"}}}},
 				},
 				Clues:        2,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4, w1},
 				Deck:         5,
+				Turn:         11,
+				Seq:          14,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Strange", Card: []int{0}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1704,17 +2569,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{Colors: []string{"B"}}, {NotColors: []string{"B"}, NotValues: []string{"1"}}, {NotColors: []string{"B"}, NotValues: []string{"1"}}, {NotColors: []string{"B"}, NotValues: []string{"1"}}, {NotColors: []string{"B"}, NotValues: []string{"1"}}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
 				},
 				Clues:        1,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4, w1},
 				Deck:         5,
+				Turn:         12,
+				Seq:          15,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Charm", TargetPlayer: "Strange", Clue: "B", Card: []int{0}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1723,17 +2591,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{}, {NotColors: []string{"B"}, NotValues: []string{"1"}}, {NotColors: []string{"B"}, NotValues: []string{"1"}}, {NotColors: []string{"B"}, NotValues: []string{"1"}}, {NotColors: []string{"B"}, NotValues: []string{"1"}}}},
 				},
 				Clues:        2,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4, w1, b5},
 				Deck:         4,
+				Turn:         13,
+				Seq:          16,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Strange", Card: []int{0}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1742,17 +2613,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2"}}, {Values: []string{"2"}, NotColors: []string{"B"}, NotValues: []string{"1"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2"}}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
 				},
 				Clues:        1,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4, w1, b5},
 				Deck:         4,
+				Turn:         14,
+				Seq:          17,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Charm", TargetPlayer: "Strange", Clue: "2", Card: []int{1}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1761,17 +2635,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2"}}, {}, {NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2"}}}},
 				},
 				Clues:        1,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4, w1, b5, w2},
 				Deck:         3,
+				Turn:         15,
+				Seq:          18,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Strange", Card: []int{1}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1780,17 +2657,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {Values: []string{"3"}}, {Values: []string{"3"}, NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}}},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
 				},
 				Clues:        0,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4, w1, b5, w2},
 				Deck:         3,
+				Turn:         16,
+				Seq:          19,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Charm", TargetPlayer: "Strange", Clue: "3", Card: []int{1, 2}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1799,17 +2679,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w1, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {}, {Values: []string{"3"}, NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}}},
 				},
 				Clues:        0,
 				Lives:        3,
 				Discards:     []model.Card{},
 				Table:        []model.Card{b1, b2, b3, b4, w1, b5, w2, w3},
 				Deck:         2,
+				Turn:         17,
+				Seq:          20,
 				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Strange", Card: []int{1}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1818,17 +2701,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{w4, b1, b1, w2, b2}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {}, {Values: []string{"3"}, NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}}},
+					{Id: "Charm", Cards: []model.Card{w4, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1"}}}},
 				},
 				Clues:        1,
 				Lives:        3,
 				Discards:     []model.Card{w1},
 				Table:        []model.Card{b1, b2, b3, b4, w1, b5, w2, w3},
 				Deck:         1,
+				Turn:         18,
+				Seq:          21,
 				PlayedAction: model.Action{Type: model.ActionDiscard, ActivePlayer: "Charm", Card: []int{0}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1837,17 +2723,20 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{w4, b1, b1, w2, b2}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{w4, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{Values: []string{"4"}}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1", "4"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {}, {Values: []string{"3"}, NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}}},
 				},
 				Clues:        0,
 				Lives:        3,
 				Discards:     []model.Card{w1},
 				Table:        []model.Card{b1, b2, b3, b4, w1, b5, w2, w3},
 				Deck:         1,
+				Turn:         19,
+				Seq:          22,
 				PlayedAction: model.Action{Type: model.ActionClue, ActivePlayer: "Strange", TargetPlayer: "Charm", Clue: "4", Card: []int{0}},
 				Started:      true,
 				Ended:        false,
+				Colors:       2,
 			},
 		},
 		{
@@ -1856,17 +2745,21 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Strange"},
-					{Id: "Charm", Cards: []model.Card{b4, b1, b1, w2, b2}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {}, {Values: []string{"3"}, NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}}},
+					{Id: "Charm", Cards: []model.Card{b4, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1", "4"}}}},
 				},
-				Clues:        0,
-				Lives:        3,
-				Discards:     []model.Card{w1},
-				Table:        []model.Card{b1, b2, b3, b4, w1, b5, w2, w3, w4},
-				Deck:         0,
-				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Charm", Card: []int{0}},
-				Started:      true,
-				Ended:        false,
+				Clues:          0,
+				Lives:          3,
+				Discards:       []model.Card{w1},
+				Table:          []model.Card{b1, b2, b3, b4, w1, b5, w2, w3, w4},
+				Deck:           0,
+				Turn:           20,
+				TurnsRemaining: 2,
+				Seq:            23,
+				PlayedAction:   model.Action{Type: model.ActionPlay, ActivePlayer: "Charm", Card: []int{0}},
+				Started:        true,
+				Ended:          false,
+				Colors:         2,
 			},
 		},
 		{
@@ -1875,17 +2768,22 @@ func TestHandleGameActions(t *testing.T) {
 			expectedState: model.GameState{
 				Id: "game",
 				Players: []model.Player{
-					{Id: "Charm", Cards: []model.Card{b4, b1, b1, w2, b2}},
-					{Id: "Strange"},
+					{Id: "Charm", Cards: []model.Card{b4, b1, b1, w2, b2}, Knowledge: []model.CardKnowledge{{}, {Values: []string{"1"}, NotValues: []string{"2", "3", "4"}}, {Values: []string{"1"}, NotValues: []string{"3", "4"}}, {NotValues: []string{"4", "1"}}, {NotValues: []string{"1", "4"}}}},
+					{Id: "Strange", Knowledge: []model.CardKnowledge{{NotValues: []string{"2", "3"}}, {}, {Values: []string{"3"}, NotColors: []string{"B"}, NotValues: []string{"1", "2"}}, {NotColors: []string{"B"}, NotValues: []string{"1", "2", "3"}}}}, // no card left to draw
+
 				},
-				Clues:        1,
-				Lives:        3,
-				Discards:     []model.Card{w1},
-				Table:        []model.Card{b1, b2, b3, b4, w1, b5, w2, w3, w4, w5},
-				Deck:         -1,
-				PlayedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Strange", Card: []int{4}},
-				Started:      true,
-				Ended:        true,
+				Clues:          1,
+				Lives:          3,
+				Discards:       []model.Card{w1},
+				Table:          []model.Card{b1, b2, b3, b4, w1, b5, w2, w3, w4, w5},
+				Deck:           0,
+				Turn:           21,
+				TurnsRemaining: 1,
+				Seq:            24,
+				PlayedAction:   model.Action{Type: model.ActionPlay, ActivePlayer: "Strange", Card: []int{4}},
+				Started:        true,
+				Ended:          true,
+				Colors:         2,
 			},
 		},
 	}
@@ -1896,15 +2794,109 @@ func TestHandleGameActions(t *testing.T) {
 			log.Info("charm: [", string(bytes), "]")
 		}
 	}()
+	// Strange is sent the state when joining, and then updates
+	state := model.GameState{}
 	for _, turn := range turns {
 		t.Run(turn.description, func(t *testing.T) {
 			actions <- &turn.action
 			strangeBytes := <-strangeConn.BytesWritten
 			log.Info("[", string(strangeBytes[:len(strangeBytes)-1]), "]")
-			state := model.GameState{}
-			err := json.Unmarshal(strangeBytes, &state)
-			assert.Nil(t, err, "failed to unmarshal state: ", strangeBytes)
+			err := applyMessage(&state, strangeBytes)
+			assert.Nil(t, err, "failed to apply message: ", strangeBytes)
 			assert.Equal(t, turn.expectedState, state)
 		})
 	}
 }
+
+func TestReplayGame_Record(t *testing.T) {
+	deck := []model.Card{w1, w2, w3, w4, w5, r1, r2, r3, r4, r5, b1, b2}
+	_, _, record := replayGame("game", deck, []*model.Action{
+		{Type: model.ActionCreate, ActivePlayer: "Up"},
+		{Type: model.ActionJoin, ActivePlayer: "Down"},
+		{Type: model.ActionStart, ActivePlayer: "Up"},
+		{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+		{Type: model.ActionClue, ActivePlayer: "Down", TargetPlayer: "Up", Clue: "B", Card: []int{}},
+	})
+	assert.Equal(t, model.GameID("game"), record.Id)
+	assert.Equal(t, []model.PlayerID{"Up", "Down"}, record.Players)
+	assert.Equal(t, model.GameOptions{}.WithDefaults(), record.Options)
+	assert.Equal(t, []model.Card{w1, w2, w3, w4, w5, r1, r2, r3, r4, r5, b1, b2}, record.Deck, "drawn cards must not change the recorded deck")
+	assert.Equal(t, []model.Action{
+		{Type: model.ActionCreate, ActivePlayer: "Up"},
+		{Type: model.ActionStart, ActivePlayer: "Up"},
+		{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+		{Type: model.ActionClue, ActivePlayer: "Down", TargetPlayer: "Up", Clue: "B", Card: []int{0}},
+	}, record.Actions)
+}
+
+func TestReplayGame_Seed(t *testing.T) {
+	play := func(seed int64) *model.GameState {
+		state, _, record := replayGame("game", model.CreateDeck(model.VariantStandard, seed), []*model.Action{
+			{Type: model.ActionCreate, ActivePlayer: "Up", Seed: &seed},
+			{Type: model.ActionJoin, ActivePlayer: "Down"},
+			{Type: model.ActionStart, ActivePlayer: "Up"},
+			{Type: model.ActionPlay, ActivePlayer: "Up", Card: []int{0}},
+			{Type: model.ActionDiscard, ActivePlayer: "Down", Card: []int{4}},
+			{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "1", Card: []int{}},
+		})
+		assert.Equal(t, seed, record.Seed)
+		return state
+	}
+	assert.Equal(t, play(42), play(42))
+	assert.NotEqual(t, play(42), play(43))
+	assert.NotEqual(t, play(0), play(1), "0 is a seed like any other")
+}
+
+func seedOf(seed int64) *int64 {
+	return &seed
+}
+
+func TestHandleGameActions_Spectator(t *testing.T) {
+	upConn := &MockConn{BytesWritten: make(chan []byte, 10)}
+	spectatorConn := &MockConn{BytesWritten: make(chan []byte, 10)}
+	game := model.Game{
+		Id: "game",
+		Connections: map[model.PlayerID]model.Connection{
+			"Up": io.NewConnection(upConn),
+		},
+		Spectators: map[model.PlayerID]model.Connection{
+			"spectator": io.NewConnection(spectatorConn),
+		},
+		Actions: make(chan *model.Action, 5),
+	}
+	go HandleGameActions(&game, []model.Card{w1, w2, w3, w4, w5, r1, r2, r3, r4, r5, b1})
+	upState := model.GameState{}
+	spectatorState := model.GameState{}
+	read := func(conn *MockConn, view *model.GameState) model.GameState {
+		assert.Nil(t, applyMessage(view, <-conn.BytesWritten))
+		return *view
+	}
+
+	game.Actions <- &model.Action{Type: model.ActionSpectate, ActivePlayer: "spectator"}
+	assert.Equal(t, model.Action{}, read(spectatorConn, &spectatorState).PlayedAction, "spectating is not an action in the game")
+
+	game.Actions <- &model.Action{Type: model.ActionCreate, ActivePlayer: "Up"}
+	game.Actions <- &model.Action{Type: model.ActionJoin, ActivePlayer: "Down"}
+	game.Actions <- &model.Action{Type: model.ActionStart, ActivePlayer: "Up"}
+	for i := 0; i < 3; i++ {
+		read(upConn, &upState)
+		read(spectatorConn, &spectatorState)
+	}
+	assert.Equal(t, []model.Player{
+		{Id: "Up", Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+		{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+	}, upState.Players)
+	assert.Equal(t, []model.Player{
+		{Id: "Up", Cards: []model.Card{w1, w2, w3, w4, w5}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+		{Id: "Down", Cards: []model.Card{r1, r2, r3, r4, r5}, Knowledge: []model.CardKnowledge{{}, {}, {}, {}, {}}},
+	}, spectatorState.Players)
+
+	game.Actions <- &model.Action{Type: model.ActionPing, ActivePlayer: "spectator"}
+	envelope, err := io.Decode(<-spectatorConn.BytesWritten)
+	assert.Nil(t, err)
+	assert.Equal(t, model.MessageState, envelope.Type, "a ping is answered with the whole state")
+	pinged := model.GameState{}
+	assert.Nil(t, json.Unmarshal(envelope.Payload, &pinged))
+	assert.Equal(t, model.ActionPing, pinged.PlayedAction.Type)
+	assert.Equal(t, 0, len(upConn.BytesWritten), "only the spectator that pinged gets the state")
+}
@@ -0,0 +1,67 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxChatLength = 200
+	// the number of messages kept for players that join
+	maxChatHistory = 50
+	// each connection may send chatBurst messages within chatWindow
+	chatBurst  = 5
+	chatWindow = 10 * time.Second
+)
+
+// addChatMessage adds the message of a chat action to the history of the game
+func addChatMessage(game *model.Game, action *model.Action) model.ChatMessage {
+	message := model.ChatMessage{Player: action.ActivePlayer, Message: action.Message}
+	game.Chat = append(game.Chat, message)
+	if len(game.Chat) > maxChatHistory {
+		game.Chat = game.Chat[len(game.Chat)-maxChatHistory:]
+	}
+	return message
+}
+
+func sendChatHistory(game *model.Game, connections map[model.PlayerID]model.Connection) {
+	if len(game.Chat) == 0 {
+		return
+	}
+	sendToAll(model.Chat{Messages: game.Chat}, connections)
+}
+
+func sendToAll(message interface{}, connections map[model.PlayerID]model.Connection) {
+	for _, conn := range connections {
+		if conn == nil {
+			continue
+		}
+		_, err := conn.Write(message)
+		if err != nil {
+			log.Error("failed to write chat")
+		}
+	}
+}
+
+// chatLimiter limits how often a connection may chat
+type chatLimiter struct {
+	sent []time.Time
+}
+
+// allow returns true, and counts the message, if fewer than chatBurst messages were sent within chatWindow
+func (l *chatLimiter) allow(now time.Time) bool {
+	recent := l.sent[:0]
+	for _, t := range l.sent {
+		if now.Sub(t) < chatWindow {
+			recent = append(recent, t)
+		}
+	}
+	l.sent = recent
+	if len(l.sent) >= chatBurst {
+		return false
+	}
+	l.sent = append(l.sent, now)
+	return true
+}
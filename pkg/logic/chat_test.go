@@ -0,0 +1,86 @@
+package logic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestChatLimiter(t *testing.T) {
+	limiter := chatLimiter{}
+	now := time.Now()
+	for i := 0; i < chatBurst; i++ {
+		assert.True(t, limiter.allow(now.Add(time.Duration(i)*time.Second)))
+	}
+	assert.False(t, limiter.allow(now.Add(chatBurst*time.Second)))
+	// the first message is out of the window
+	assert.True(t, limiter.allow(now.Add(chatWindow)))
+	assert.False(t, limiter.allow(now.Add(chatWindow)))
+}
+
+func TestHandleGameActions_Chat(t *testing.T) {
+	upConn := NewMockConnection()
+	downConn := NewMockConnection()
+	spectatorConn := NewMockConnection()
+	game := model.Game{
+		Id:          "game",
+		Connections: map[model.PlayerID]model.Connection{"Up": upConn, "Down": downConn},
+		Spectators:  map[model.PlayerID]model.Connection{"spectator": spectatorConn},
+		Actions:     make(chan *model.Action, 5),
+	}
+	go HandleGameActions(&game, []model.Card{w1, w2, w3, w4, w5, r1, r2, r3, r4, r5, b1})
+	views := map[*MockConnection]*model.GameState{}
+	readState := func(conn *MockConnection) model.GameState {
+		if views[conn] == nil {
+			views[conn] = &model.GameState{}
+		}
+		assert.Nil(t, applyMessage(views[conn], <-conn.Messages))
+		return *views[conn]
+	}
+	readChat := func(conn *MockConnection) model.Chat {
+		chat := model.Chat{}
+		assert.Nil(t, decodePayload(<-conn.Messages, &chat))
+		return chat
+	}
+
+	game.Actions <- &model.Action{Type: model.ActionCreate, ActivePlayer: "Up"}
+	game.Actions <- &model.Action{Type: model.ActionJoin, ActivePlayer: "Down"}
+	for _, conn := range []*MockConnection{upConn, downConn, spectatorConn} {
+		readState(conn)
+		readState(conn)
+	}
+
+	game.Actions <- &model.Action{Type: model.ActionChat, ActivePlayer: "Down", Message: "hi"}
+	hi := model.Chat{Messages: []model.ChatMessage{{Player: "Down", Message: "hi"}}}
+	assert.Equal(t, hi, readChat(upConn))
+	assert.Equal(t, hi, readChat(downConn))
+	assert.Equal(t, hi, readChat(spectatorConn))
+
+	game.Actions <- &model.Action{Type: model.ActionPing, ActivePlayer: "Up"}
+	assert.Equal(t, model.ActionPing, readState(upConn).PlayedAction.Type, "chat is not a played action")
+
+	for i := 0; i < maxChatHistory; i++ {
+		game.Actions <- &model.Action{Type: model.ActionChat, ActivePlayer: "Up", Message: fmt.Sprint(i)}
+		for _, conn := range []*MockConnection{upConn, downConn, spectatorConn} {
+			readChat(conn)
+		}
+	}
+
+	// Down rejoins on a new connection
+	rejoinConn := NewMockConnection()
+	game.Lock()
+	game.Connections["Down"] = rejoinConn
+	game.Unlock()
+	game.Actions <- &model.Action{Type: model.ActionJoin, ActivePlayer: "Down"}
+	assert.Equal(t, model.ActionJoin, readState(rejoinConn).PlayedAction.Type)
+	history := readChat(rejoinConn)
+	assert.Equal(t, maxChatHistory, len(history.Messages), "the history is bounded")
+	assert.Equal(t, model.ChatMessage{Player: "Up", Message: "0"}, history.Messages[0])
+	assert.Equal(t, model.ChatMessage{Player: "Up", Message: fmt.Sprint(maxChatHistory - 1)}, history.Messages[maxChatHistory-1])
+	readState(upConn)
+	assert.Equal(t, 0, len(upConn.Messages), "only the joining player gets the history")
+}
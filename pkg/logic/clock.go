@@ -0,0 +1,157 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// turnClock times the turns of a timed game. It belongs to the goroutine running the game.
+// A restored game starts over with full time banks.
+type turnClock struct {
+	turn    time.Duration
+	banks   map[model.PlayerID]time.Duration
+	player  model.PlayerID
+	started time.Time
+	timer   *time.Timer
+}
+
+// newTurnClock returns nil if the game is not timed
+func newTurnClock(options model.GameOptions, players []model.Player) *turnClock {
+	if !options.IsTimed() {
+		return nil
+	}
+	c := &turnClock{
+		turn:  time.Duration(options.TurnSeconds) * time.Second,
+		banks: make(map[model.PlayerID]time.Duration, len(players)),
+		timer: time.NewTimer(time.Hour),
+	}
+	c.timer.Stop()
+	for _, player := range players {
+		c.banks[player.Id] = time.Duration(options.BankSeconds) * time.Second
+	}
+	return c
+}
+
+// startTurn starts the time of the player whose turn it is
+func (c *turnClock) startTurn(player model.PlayerID, now time.Time) {
+	c.player = player
+	c.started = now
+	c.stop()
+	c.timer.Reset(c.turn + c.banks[player])
+}
+
+// endTurn takes the time spent over the turn from the time bank of the player
+func (c *turnClock) endTurn(now time.Time) {
+	c.banks[c.player] = c.bankLeft(now)
+}
+
+// expired receives when the player whose turn it is has run out of time
+func (c *turnClock) expired() <-chan time.Time {
+	return c.timer.C
+}
+
+func (c *turnClock) stop() {
+	if !c.timer.Stop() {
+		// the timer fired, but the time was never received
+		select {
+		case <-c.timer.C:
+		default:
+		}
+	}
+}
+
+func (c *turnClock) report(now time.Time) *model.Clock {
+	clock := &model.Clock{
+		Turn:  0,
+		Banks: make(map[model.PlayerID]int64, len(c.banks)),
+	}
+	if left := c.turn - now.Sub(c.started); left > 0 {
+		clock.Turn = left.Milliseconds()
+	}
+	for player, bank := range c.banks {
+		if player == c.player {
+			bank = c.bankLeft(now)
+		}
+		clock.Banks[player] = bank.Milliseconds()
+	}
+	return clock
+}
+
+func (c *turnClock) bankLeft(now time.Time) time.Duration {
+	bank := c.banks[c.player]
+	if over := now.Sub(c.started) - c.turn; over > 0 {
+		bank -= over
+	}
+	if bank < 0 {
+		return 0
+	}
+	return bank
+}
+
+// timeoutAction is played for the player whose time ran out
+func timeoutAction(state *model.GameState, record *model.GameRecord) *model.Action {
+	player := state.Players[0]
+	if state.Options.OnTimeout == model.TimeoutEnd {
+		return &model.Action{Type: model.ActionTimeout, ActivePlayer: player.Id}
+	}
+	return &model.Action{
+		Type:         model.ActionDiscard,
+		ActivePlayer: player.Id,
+		Card:         []int{oldestUncluedCard(player, drawOrder(record)[player.Id])},
+	}
+}
+
+// drawOrder returns the order in which the cards in the hands were dealt or drawn, by their index in the hand
+func drawOrder(record *model.GameRecord) map[model.PlayerID][]int {
+	cardsPerPlayer := record.Options.CardsPerPlayer(len(record.Players))
+	order := make(map[model.PlayerID][]int, len(record.Players))
+	drawn := 0
+	for _, player := range record.Players {
+		order[player] = make([]int, cardsPerPlayer)
+		for i := range order[player] {
+			order[player][i] = drawn
+			drawn++
+		}
+	}
+	for _, action := range record.Actions {
+		if action.Type != model.ActionPlay && action.Type != model.ActionDiscard {
+			continue
+		}
+		index := action.Card[0]
+		if drawn < len(record.Deck) {
+			order[action.ActivePlayer][index] = drawn
+			drawn++
+		} else {
+			// the deck is empty, and the hand shrinks
+			hand := order[action.ActivePlayer]
+			order[action.ActivePlayer] = append(hand[:index:index], hand[index+1:]...)
+		}
+	}
+	return order
+}
+
+// oldestUncluedCard returns the index of the card that was drawn first of the cards without
+// positive clues, or of all cards if every card has been clued
+func oldestUncluedCard(player model.Player, order []int) int {
+	oldest := -1
+	oldestClued := 0
+	for i := range player.Cards {
+		if i >= len(order) {
+			break
+		}
+		clued := i < len(player.Knowledge) &&
+			(len(player.Knowledge[i].Colors) > 0 || len(player.Knowledge[i].Values) > 0)
+		if clued {
+			if order[i] < order[oldestClued] {
+				oldestClued = i
+			}
+		} else if oldest < 0 || order[i] < order[oldest] {
+			oldest = i
+		}
+	}
+	if oldest < 0 {
+		return oldestClued
+	}
+	return oldest
+}
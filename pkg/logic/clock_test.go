@@ -0,0 +1,157 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestTurnClock(t *testing.T) {
+	players := []model.Player{{Id: "Up"}, {Id: "Down"}}
+	assert.Nil(t, newTurnClock(model.GameOptions{}, players), "games are not timed by default")
+
+	clock := newTurnClock(model.GameOptions{TurnSeconds: 10, BankSeconds: 20}, players)
+	defer clock.stop()
+	now := time.Now()
+	clock.startTurn("Up", now)
+	assert.Equal(t, &model.Clock{Turn: 6000, Banks: map[model.PlayerID]int64{"Up": 20000, "Down": 20000}},
+		clock.report(now.Add(4*time.Second)))
+	assert.Equal(t, &model.Clock{Turn: 0, Banks: map[model.PlayerID]int64{"Up": 15000, "Down": 20000}},
+		clock.report(now.Add(15*time.Second)))
+
+	clock.endTurn(now.Add(15 * time.Second))
+	clock.startTurn("Down", now.Add(15*time.Second))
+	assert.Equal(t, &model.Clock{Turn: 0, Banks: map[model.PlayerID]int64{"Up": 15000, "Down": 0}},
+		clock.report(now.Add(time.Minute)), "the bank never goes below zero")
+
+	totalOnly := newTurnClock(model.GameOptions{BankSeconds: 60}, players)
+	defer totalOnly.stop()
+	totalOnly.startTurn("Up", now)
+	assert.Equal(t, &model.Clock{Turn: 0, Banks: map[model.PlayerID]int64{"Up": 59000, "Down": 60000}},
+		totalOnly.report(now.Add(time.Second)))
+}
+
+func TestTimeoutAction(t *testing.T) {
+	record := model.GameRecord{
+		Options: model.GameOptions{HandSize: 3}.WithDefaults(),
+		Players: []model.PlayerID{"Up", "Down"},
+		Deck:    []model.Card{w1, w2, w3, r1, r2, r3, w4, r4},
+		Actions: []model.Action{
+			{Type: model.ActionStart, ActivePlayer: "Up"},
+			{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{0}},
+			{Type: model.ActionPlay, ActivePlayer: "Down", Card: []int{1}},
+		},
+	}
+	testCases := []struct {
+		description string
+		onTimeout   string
+		knowledge   []model.CardKnowledge
+		expected    *model.Action
+	}{
+		{
+			description: "Discard oldest card",
+			knowledge:   []model.CardKnowledge{{}, {}, {}},
+			expected:    &model.Action{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{1}},
+		},
+		{
+			description: "Discard oldest card that is not clued",
+			knowledge:   []model.CardKnowledge{{}, {Values: []string{"1"}}, {NotColors: []string{"R"}}},
+			expected:    &model.Action{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{2}},
+		},
+		{
+			description: "Discard oldest card when every card is clued",
+			knowledge:   []model.CardKnowledge{{Colors: []string{"R"}}, {Values: []string{"1"}}, {Colors: []string{"B"}}},
+			expected:    &model.Action{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{1}},
+		},
+		{
+			description: "End game",
+			onTimeout:   model.TimeoutEnd,
+			knowledge:   []model.CardKnowledge{{}, {}, {}},
+			expected:    &model.Action{Type: model.ActionTimeout, ActivePlayer: "Up"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			state := &model.GameState{
+				Players: []model.Player{
+					{Id: "Up", Cards: []model.Card{w1, w2, w3}, Knowledge: tc.knowledge},
+					{Id: "Down", Cards: []model.Card{r1, r2, r3}, Knowledge: []model.CardKnowledge{{}, {}, {}}},
+				},
+				Options: model.GameOptions{TurnSeconds: 30, OnTimeout: tc.onTimeout},
+			}
+			assert.Equal(t, tc.expected, timeoutAction(state, &record))
+		})
+	}
+}
+
+func TestHandleGameActions_Timeout(t *testing.T) {
+	views := map[*MockConnection]*model.GameState{}
+	readState := func(conn *MockConnection) model.GameState {
+		if views[conn] == nil {
+			views[conn] = &model.GameState{}
+		}
+		assert.Nil(t, applyMessage(views[conn], <-conn.Messages))
+		return *views[conn]
+	}
+	startGame := func(options model.GameOptions) (*model.Game, *MockConnection, *MockConnection) {
+		upConn := NewMockConnection()
+		downConn := NewMockConnection()
+		game := &model.Game{
+			Id:          "game",
+			Connections: map[model.PlayerID]model.Connection{"Up": upConn, "Down": downConn},
+			Actions:     make(chan *model.Action, 5),
+		}
+		go HandleGameActions(game, []model.Card{w1, w2, w3, w4, w5, r1, r2, r3, r4, r5, b1, b2})
+		game.Actions <- &model.Action{Type: model.ActionCreate, ActivePlayer: "Up", Options: &options}
+		game.Actions <- &model.Action{Type: model.ActionJoin, ActivePlayer: "Down"}
+		game.Actions <- &model.Action{Type: model.ActionStart, ActivePlayer: "Up"}
+		for _, conn := range []*MockConnection{upConn, downConn} {
+			readState(conn)
+			readState(conn)
+		}
+		return game, upConn, downConn
+	}
+
+	t.Run("Discard", func(t *testing.T) {
+		game, upConn, downConn := startGame(model.GameOptions{TurnSeconds: 1})
+		started := readState(upConn)
+		readState(downConn)
+		assert.NotNil(t, started.Clock)
+		assert.True(t, started.Clock.Turn > 0 && started.Clock.Turn <= 1000)
+		assert.Equal(t, map[model.PlayerID]int64{"Up": 0, "Down": 0}, started.Clock.Banks)
+
+		game.Actions <- &model.Action{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "1", Card: []int{}}
+		readState(upConn)
+		readState(downConn)
+
+		// Down lets the time run out, and the oldest card without clues is discarded
+		timedOut := readState(upConn)
+		readState(downConn)
+		assert.Equal(t, model.Action{Type: model.ActionDiscard, ActivePlayer: "Down", Card: []int{1}}, timedOut.PlayedAction)
+		assert.Equal(t, []model.Card{r2}, timedOut.Discards)
+
+		// an action that was on its way when the time ran out is ignored
+		game.Actions <- &model.Action{Type: model.ActionPlay, ActivePlayer: "Down", Card: []int{0}}
+		game.Actions <- &model.Action{Type: model.ActionPing, ActivePlayer: "Up"}
+		pinged := readState(upConn)
+		assert.Equal(t, model.ActionPing, pinged.PlayedAction.Type)
+		assert.Equal(t, 0, len(pinged.Table))
+		assert.Equal(t, model.PlayerID("Up"), pinged.Players[0].Id)
+		assert.Equal(t, 0, len(downConn.Messages))
+	})
+
+	t.Run("End", func(t *testing.T) {
+		_, upConn, downConn := startGame(model.GameOptions{TurnSeconds: 1, OnTimeout: model.TimeoutEnd})
+		readState(upConn)
+		readState(downConn)
+
+		ended := readState(upConn)
+		assert.True(t, ended.Ended)
+		assert.Equal(t, model.Action{Type: model.ActionTimeout, ActivePlayer: "Up"}, ended.PlayedAction)
+		assert.Equal(t, int64(0), ended.Clock.Turn)
+		assert.True(t, readState(downConn).Ended)
+	})
+}
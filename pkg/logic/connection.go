@@ -1,72 +1,114 @@
 package logic
 
 import (
+	"errors"
 	"net"
-	"net/http"
-
-	"github.com/egoon/hanabi-server/pkg/io"
+	"time"
 
 	"github.com/egoon/hanabi-server/pkg/model"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
-func HandleConnection(conn net.Conn, games map[model.GameID]*model.Game, gameChan chan *model.Game) {
+func HandleConnection(conn model.Connection, games *GameRegistry) {
 	defer conn.Close()
-	ar := io.NewActionReader(conn)
-	writer := io.NewJsonWriter(conn)
+	defer games.Lobby.Unsubscribe(conn)
 	var game *model.Game
 	var playerID model.PlayerID
+	spectating := false
+	defer func() {
+		if game != nil {
+			disconnect(game, playerID, conn, spectating)
+		}
+	}()
+	subscribed := false
+	chat := chatLimiter{}
 	for {
-		action, err := ar.ReadAction()
+		action, err := conn.ReadAction()
 		if err != nil {
 			netErr, ok := err.(net.Error)
 			if ok && netErr.Timeout() {
 				log.Info("Connection timed out:", err)
-				_, _ = writer.Write(model.Error{Err: http.StatusGatewayTimeout})
+				writeError(conn, model.NewError(model.ErrTimeout, "connection timed out"), nil)
 				break
 			}
 			log.Warn("Failed to read from client: ", err)
-			_, _ = writer.Write(model.Error{Err: http.StatusBadRequest})
+			readErr := &model.Error{}
+			if !errors.As(err, &readErr) {
+				readErr = model.NewError(model.ErrBadRequest, "failed to read message")
+			}
+			writeError(conn, readErr, nil)
 			break
 		}
-		if playerID == "" {
+		if game == nil {
 			if action.ActivePlayer == "" {
-				playerID = model.PlayerID(uuid.New().String())
-			} else {
-				playerID = action.ActivePlayer
+				if playerID == "" {
+					playerID = model.PlayerID(uuid.New().String())
+				}
+				action.ActivePlayer = playerID
 			}
-		}
-		if game == nil {
 			err = ValidateAndCleanAction(action, nil)
 			if err != nil {
 				log.Info("validate action failed: ", err)
-
-				_, err = writer.Write(model.Error{Err: http.StatusBadRequest, Message: err.Error()})
+				writeError(conn, err, action)
+			} else if action.Type == model.ActionListGames {
+				_, err = conn.Write(games.Lobby.List())
 				if err != nil {
 					log.Warn("failed to send message to client: ", err)
 				}
+			} else if action.Type == model.ActionSubscribeLobby {
+				games.Lobby.Subscribe(conn)
+				subscribed = true
 			} else {
-				game, err = ConnectToGame(action, conn, games, gameChan)
+				// the lobby must not be mixed with the states of the game
+				games.Lobby.Unsubscribe(conn)
+				var token string
+				game, token, err = ConnectToGame(action, conn, games)
 				if err != nil {
-					_, err = writer.Write(model.Error{Err: http.StatusBadRequest, Message: err.Error()})
-					if err != nil {
-						log.Warn("failed to send message to client: ", err)
+					writeError(conn, err, action)
+					if subscribed {
+						games.Lobby.Subscribe(conn)
 					}
 				} else {
-					_, err = writer.Write(model.GameState{Id: game.Id})
+					// from now on this connection may only act as this player
+					playerID = action.ActivePlayer
+					spectating = action.Type == model.ActionSpectate
+					reply := model.GameState{Id: game.Id}
+					if !spectating {
+						reply.Session = &model.Session{Player: playerID, Token: token}
+					}
+					_, err = conn.Write(reply)
 					if err != nil {
 						log.Warn("failed to send message to client: ", err)
 					}
 				}
 			}
 		} else {
-			err = ValidateAndCleanAction(action, game.State)
+			err = bindActionToPlayer(action, playerID)
+			if err == nil && spectating && action.Type != model.ActionPing && action.Type != model.ActionResync && action.Type != model.ActionLeave {
+				err = model.NewError(model.ErrForbidden, "spectators may only ping, resync or leave")
+			}
+			if err == nil {
+				err = ValidateAndCleanAction(action, game.Snapshot())
+			}
+			if err == nil && action.Type == model.ActionChat && !chat.allow(time.Now()) {
+				err = model.NewError(model.ErrRateLimited, "too many chat messages. wait a moment")
+			}
 			if err != nil {
 				log.Info("validate action failed: ", err)
-				_, err = writer.Write(model.Error{Err: http.StatusBadRequest, Message: err.Error()})
+				writeError(conn, err, action)
+			} else if action.Type == model.ActionLeave {
+				leaveGame(game, playerID, spectating)
+				if !spectating {
+					game.Actions <- action
+				}
+				// the connection is back in the lobby, and may create or join another game
+				game = nil
+				spectating = false
+			} else if action.Type == model.ActionAddBot {
+				err = AddBot(action, game, games)
 				if err != nil {
-					log.Warn("failed to send message to client: ", err)
+					writeError(conn, err, action)
 				}
 			} else {
 				game.Actions <- action
@@ -74,3 +116,31 @@ func HandleConnection(conn net.Conn, games map[model.GameID]*model.Game, gameCha
 		}
 	}
 }
+
+// bindActionToPlayer makes the action act on behalf of the player the connection is bound to.
+// Actions claiming to be made by another player are rejected.
+func bindActionToPlayer(action *model.Action, playerID model.PlayerID) error {
+	if action.ActivePlayer != "" && action.ActivePlayer != playerID {
+		return model.NewError(model.ErrForbidden, "connection belongs to player %s. may not act as %s", playerID, action.ActivePlayer)
+	}
+	action.ActivePlayer = playerID
+	return nil
+}
+
+// writeError sends an error to the client, with the id of the action that failed.
+// Errors without a code are failures of the server.
+func writeError(conn model.Connection, err error, action *model.Action) {
+	modelErr, ok := err.(*model.Error)
+	if !ok {
+		modelErr = model.NewError(model.ErrServerError, "%s", err)
+	}
+	if action != nil {
+		withId := *modelErr
+		withId.ActionId = action.Id
+		modelErr = &withId
+	}
+	_, err = conn.Write(modelErr)
+	if err != nil {
+		log.Warn("failed to send message to client: ", err)
+	}
+}
@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/io"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestBindActionToPlayer(t *testing.T) {
+	testCases := []struct {
+		description    string
+		action         model.Action
+		expectedAction model.Action
+		expectedError  error
+	}{
+		{
+			description:    "No active player - bound",
+			action:         model.Action{Type: model.ActionPlay},
+			expectedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up"},
+		},
+		{
+			description:    "Same active player - ok",
+			action:         model.Action{Type: model.ActionPlay, ActivePlayer: "Up"},
+			expectedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Up"},
+		},
+		{
+			description:    "Other active player - fail",
+			action:         model.Action{Type: model.ActionPlay, ActivePlayer: "Down"},
+			expectedAction: model.Action{Type: model.ActionPlay, ActivePlayer: "Down"},
+			expectedError:  model.NewError(model.ErrForbidden, "connection belongs to player Up. may not act as Down"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := bindActionToPlayer(&tc.action, "Up")
+			assert.Equal(t, tc.expectedAction, tc.action)
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestHandleConnection_Errors(t *testing.T) {
+	games := NewGameRegistry()
+	conn := NewMockConnection()
+	go HandleConnection(conn, games)
+	readJSON := func() string {
+		return payload(t, <-conn.Messages)
+	}
+
+	conn.Actions <- &model.Action{Type: model.ActionPlay, Id: "a1", Card: []int{0}}
+	assert.JSONEq(t, `{"code": "not_in_game", "message": "not connected to a game", "id": "a1"}`, readJSON())
+
+	conn.Actions <- &model.Action{Type: "shuffle"}
+	assert.JSONEq(t, `{"code": "unknown_action", "message": "unknown action: shuffle"}`, readJSON())
+
+	conn.Actions <- &model.Action{Type: model.ActionJoin, Id: "a2", GameID: "missing"}
+	assert.JSONEq(t, `{"code": "game_not_found", "message": "cannot join game. game does not exist", "id": "a2"}`, readJSON())
+
+	conn.Actions <- &model.Action{Type: model.ActionCreate, Id: "a3", GameID: "go", ActivePlayer: "Up"}
+	reply := model.GameState{}
+	assert.Nil(t, decodePayload(<-conn.Messages, &reply))
+	created := model.GameState{}
+	assert.Nil(t, decodePayload(<-conn.Messages, &created))
+	assert.Equal(t, "a3", created.PlayedAction.Id, "the id of a successful action is sent with the state")
+
+	conn.Actions <- &model.Action{Type: model.ActionStart, Id: "a4"}
+	assert.JSONEq(t, `{"code": "too_few_players", "message": "too few players", "id": "a4"}`, readJSON())
+	_ = conn.Close()
+}
+
+func TestHandleConnection_Leave(t *testing.T) {
+	games := NewGameRegistry()
+	up := NewMockConnection()
+	down := NewMockConnection()
+	go HandleConnection(up, games)
+	go HandleConnection(down, games)
+	read := func(conn *MockConnection) model.Envelope {
+		envelope, err := io.Decode(<-conn.Messages)
+		assert.Nil(t, err)
+		return envelope
+	}
+	view := model.GameState{}
+
+	up.Actions <- &model.Action{Type: model.ActionCreate, GameID: "go", ActivePlayer: "Up"}
+	read(up) // the session
+	assert.Nil(t, applyMessage(&view, <-up.Messages))
+	down.Actions <- &model.Action{Type: model.ActionJoin, GameID: "go", ActivePlayer: "Down"}
+	read(down) // the session
+	read(down)
+	assert.Nil(t, applyMessage(&view, <-up.Messages))
+	assert.Equal(t, []model.Player{{Id: "Up"}, {Id: "Down"}}, view.Players)
+
+	down.Actions <- &model.Action{Type: model.ActionLeave}
+	assert.Nil(t, applyMessage(&view, <-up.Messages))
+	assert.Equal(t, []model.Player{{Id: "Up"}}, view.Players)
+	assert.Equal(t, model.ActionLeave, view.PlayedAction.Type)
+	game, _ := games.Get("go")
+	_, seated := game.CopyConnections()["Down"]
+	assert.False(t, seated)
+
+	// the connections that left are back in the lobby
+	down.Actions <- &model.Action{Type: model.ActionListGames}
+	assert.Equal(t, model.MessageLobby, read(down).Type)
+	up.Actions <- &model.Action{Type: model.ActionLeave}
+	up.Actions <- &model.Action{Type: model.ActionListGames}
+	assert.Equal(t, model.MessageLobby, read(up).Type)
+	waitForGames(games, 0)
+	assert.Equal(t, 0, games.Len(), "the game ends when its last player leaves")
+	_ = up.Close()
+	_ = down.Close()
+}
+
+func TestHandleConnection_JoinStarted(t *testing.T) {
+	games := NewGameRegistry()
+	up := NewMockConnection()
+	down := NewMockConnection()
+	late := NewMockConnection()
+	for _, conn := range []*MockConnection{up, down, late} {
+		go HandleConnection(conn, games)
+	}
+	up.Actions <- &model.Action{Type: model.ActionCreate, GameID: "go", ActivePlayer: "Up"}
+	<-up.Messages // the session
+	<-up.Messages
+	down.Actions <- &model.Action{Type: model.ActionJoin, GameID: "go", ActivePlayer: "Down"}
+	<-down.Messages // the session
+	<-down.Messages
+	<-up.Messages
+	up.Actions <- &model.Action{Type: model.ActionStart}
+	<-up.Messages
+	<-down.Messages
+
+	late.Actions <- &model.Action{Type: model.ActionJoin, Id: "a1", GameID: "go", ActivePlayer: "Late"}
+	assert.JSONEq(t, `{"code": "game_started", "message": "cannot join game. game already started. spectate it instead", "id": "a1"}`, payload(t, <-late.Messages))
+	game, _ := games.Get("go")
+	_, seated := game.CopyConnections()["Late"]
+	assert.False(t, seated)
+
+	// a join that was let in before the start was handled is sent away by the game
+	rejected := NewMockConnection()
+	game.Lock()
+	game.Connections["Early"] = rejected
+	game.Sessions["Early"] = "token"
+	game.Unlock()
+	game.Actions <- &model.Action{Type: model.ActionJoin, Id: "a2", ActivePlayer: "Early"}
+	assert.JSONEq(t, `{"code": "game_started", "message": "cannot join game. game already started. spectate it instead", "id": "a2"}`, payload(t, <-rejected.Messages))
+	_, seated = game.CopyConnections()["Early"]
+	assert.False(t, seated)
+	_, err := rejected.ReadAction()
+	assert.NotNil(t, err, "the connection is closed")
+	assert.Equal(t, 0, len(up.Messages), "the players are not told")
+	for _, conn := range []*MockConnection{up, down, late} {
+		_ = conn.Close()
+	}
+}
+
+func TestHandleConnection_Idle(t *testing.T) {
+	games := NewGameRegistry()
+	games.IdleTimeout = 40 * time.Millisecond
+	up := NewMockConnection()
+	go HandleConnection(up, games)
+	up.Actions <- &model.Action{Type: model.ActionCreate, GameID: "go", ActivePlayer: "Up"}
+	<-up.Messages // the session
+	<-up.Messages
+	game, _ := games.Get("go")
+
+	time.Sleep(2 * games.IdleTimeout)
+	assert.Equal(t, 1, games.Len(), "a game with a connected player is kept")
+	_ = up.Close()
+	waitForGames(games, 0)
+	assert.Equal(t, 0, games.Len(), "a game without connections is abandoned")
+	conn, seated := game.CopyConnections()["Up"]
+	assert.True(t, seated, "the player keeps the seat when disconnected")
+	assert.Nil(t, conn)
+	assert.Equal(t, model.ActionAbandon, game.State.PlayedAction.Type)
+}
+
+func TestIdleCheckInterval(t *testing.T) {
+	assert.Equal(t, 10*time.Second, idleCheckInterval(40*time.Second))
+	assert.Equal(t, time.Millisecond, idleCheckInterval(3*time.Nanosecond), "a ticker needs a positive interval")
+}
+
+// waitForGames waits at most a second for the number of games to be n
+func waitForGames(games *GameRegistry, n int) {
+	deadline := time.Now().Add(time.Second)
+	for games.Len() != n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	conn := NewMockConnection()
+	writeError(conn, fmt.Errorf("disk full"), &model.Action{Id: "a1"})
+	assert.JSONEq(t, `{"code": "server_error", "message": "disk full", "id": "a1"}`, payload(t, <-conn.Messages))
+
+	notYourTurn := model.NewError(model.ErrNotYourTurn, "not your turn")
+	writeError(conn, notYourTurn, &model.Action{Id: "a2"})
+	assert.JSONEq(t, `{"code": "not_your_turn", "message": "not your turn", "id": "a2"}`, payload(t, <-conn.Messages))
+	assert.Equal(t, "", notYourTurn.ActionId, "the error itself is not changed")
+}
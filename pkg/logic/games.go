@@ -2,73 +2,344 @@ package logic
 
 import (
 	"fmt"
-	"net"
+	"os"
+	"time"
 
+	"github.com/egoon/hanabi-server/pkg/bot"
+	"github.com/egoon/hanabi-server/pkg/export"
 	"github.com/egoon/hanabi-server/pkg/model"
+	"github.com/egoon/hanabi-server/pkg/store"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
 )
 
-func HandleNewGames(games map[model.GameID]*model.Game, gameChan chan *model.Game) {
-	for {
-		game := <-gameChan
-		if games[game.Id] != nil {
-			for _, conn := range game.Connections {
-				_ = conn.Close()
-			}
-		} else {
-			games[game.Id] = game
-		}
-	}
-}
-
-func ConnectToGame(action *model.Action, conn net.Conn, games map[model.GameID]*model.Game, gameChan chan *model.Game) (*model.Game, error) {
+// ConnectToGame creates, joins or spectates a game. On success it returns the game and the session token
+// the player must present to reconnect. Spectators get no token.
+func ConnectToGame(action *model.Action, conn model.Connection, games *GameRegistry) (*model.Game, string, error) {
 	playerID := action.ActivePlayer
 	switch action.Type {
 	case "create":
-		_, ok := games[action.GameID]
-		if ok {
-			return nil, fmt.Errorf("cannot create game. game already exists")
+		actions := games.newActions()
+		token := newSessionToken()
+		options := model.GameOptions{}
+		if action.Options != nil {
+			options = *action.Options
 		}
-		actions := make(chan *model.Action, 5)
 		game := &model.Game{
-			Id: action.GameID,
-			Connections: map[model.PlayerID]net.Conn{
+			Id:      action.GameID,
+			Options: options.WithDefaults(),
+			Connections: map[model.PlayerID]model.Connection{
 				playerID: conn,
 			},
-			Actions: actions,
+			Spectators: map[model.PlayerID]model.Connection{},
+			Sessions: map[model.PlayerID]string{
+				playerID: token,
+			},
+			Actions:     actions,
+			Publish:     games.Lobby.Update,
+			IdleTimeout: games.IdleTimeout,
+		}
+		if action.Seed == nil {
+			seed := time.Now().UnixNano()
+			action.Seed = &seed
+		}
+		deck := model.CreateDeck(options.Variant, *action.Seed)
+		if games.Store != nil {
+			gameLog, err := games.Store.Create(game.Id)
+			if os.IsExist(err) {
+				return nil, "", model.NewError(model.ErrGameExists, "cannot create game. game already exists")
+			} else if err != nil {
+				log.Error("failed to store game ", game.Id, ": ", err)
+				return nil, "", model.NewError(model.ErrServerError, "cannot create game. failed to store game")
+			}
+			game.Log = gameLog
+			logError(game, gameLog.WriteDeck(deck))
+			logError(game, gameLog.WriteSession(model.Session{Player: playerID, Token: token}))
+		}
+		// queue the create action before the game is visible to others, so the creator gets the first seat
+		game.Actions <- action
+		if err := games.Create(game); err != nil {
+			if game.Log != nil {
+				_ = game.Log.Close(true)
+			}
+			if err == errShuttingDown {
+				return nil, "", model.NewError(model.ErrShuttingDown, "cannot create game. %s", err)
+			}
+			return nil, "", model.NewError(model.ErrGameExists, "cannot create game. game already exists")
 		}
-		// send game to async game handler function HandleNewGames
-		gameChan <- game
 		// create an async func to handle the new games actions
 		go func() {
-			HandleGameActions(game, model.CreateDeck())
-			delete(games, game.Id)
+			record := HandleGameActions(game, deck)
+			endGame(record, games)
 		}()
-		game.Actions <- &model.Action{
-			Type:         "join",
-			ActivePlayer: playerID,
-		}
-		return game, nil
+		return game, token, nil
 	case "join":
-		game := games[action.GameID]
-		if game == nil {
-			//msg, _ := json.Marshal(model.Error{Err: http.StatusNotFound})
-			//conn.Write(msg)
-			return nil, fmt.Errorf("cannot join game. game does not exist")
-		}
-		previousConn := game.Connections[playerID]
-		if previousConn != nil {
+		game, ok := games.Get(action.GameID)
+		if !ok {
+			return nil, "", model.NewError(model.ErrGameNotFound, "cannot join game. game does not exist")
+		}
+		token, err := joinGame(game, playerID, action.Token, conn)
+		if err != nil {
+			return nil, "", err
+		}
+		// the token is secret, and must not be broadcast with the played action
+		action.Token = ""
+		game.Actions <- action
+		return game, token, nil
+	case model.ActionSpectate:
+		game, ok := games.Get(action.GameID)
+		if !ok {
+			return nil, "", model.NewError(model.ErrGameNotFound, "cannot spectate game. game does not exist")
+		}
+		// spectators get an id of their own, so they can never be mistaken for a player
+		action.ActivePlayer = model.PlayerID("spectator-" + uuid.New().String())
+		game.Lock()
+		if game.Spectators == nil {
+			game.Spectators = map[model.PlayerID]model.Connection{}
+		}
+		game.Spectators[action.ActivePlayer] = conn
+		game.Unlock()
+		game.Actions <- action
+		return game, "", nil
+	default:
+		return nil, "", model.NewError(model.ErrNotInGame, "invalid action: %s. not in a game", action.Type)
+	}
+}
+
+// joinGame adds the connection to the game. A player that already has a session is only
+// let back in with the matching token, and then takes over from its previous connection.
+// New players can only join before the game starts.
+func joinGame(game *model.Game, playerID model.PlayerID, token string, conn model.Connection) (string, error) {
+	snapshot := game.Snapshot()
+	game.Lock()
+	defer game.Unlock()
+	if game.Sessions == nil {
+		game.Sessions = map[model.PlayerID]string{}
+	}
+	if sessionToken, ok := game.Sessions[playerID]; ok {
+		if token != sessionToken {
+			return "", model.NewError(model.ErrInvalidSession, "cannot join game. player %s is already in the game and the session token is invalid", playerID)
+		}
+		if previousConn := game.Connections[playerID]; previousConn != nil {
 			_ = previousConn.Close()
-		} else if len(game.Connections) > 4 {
-			//msg, _ := json.Marshal(model.Error{Err: http.StatusPreconditionFailed})
-			//conn.Write(msg)
-			return nil, fmt.Errorf("cannot join game. too many connections")
 		}
 		game.Connections[playerID] = conn
+		return sessionToken, nil
+	}
+	if snapshot != nil && snapshot.Started {
+		return "", model.NewError(model.ErrGameStarted, "cannot join game. game already started. spectate it instead")
+	}
+	if takenSeats(game, snapshot) >= game.Options.WithDefaults().MaxPlayers {
+		return "", model.NewError(model.ErrGameFull, "cannot join game. too many connections")
+	}
+	sessionToken := newSessionToken()
+	game.Sessions[playerID] = sessionToken
+	if game.Log != nil {
+		logError(game, game.Log.WriteSession(model.Session{Player: playerID, Token: sessionToken}))
+	}
+	game.Connections[playerID] = conn
+	return sessionToken, nil
+}
+
+// takenSeats counts the seats of the game, and the players that have joined but are not seated yet.
+// Players that are seated may not be connected, e.g. in a restored game. It must be called with the
+// lock of the game held.
+func takenSeats(game *model.Game, snapshot *model.GameState) int {
+	taken := map[model.PlayerID]bool{}
+	if snapshot != nil {
+		for _, player := range snapshot.Players {
+			taken[player.Id] = true
+		}
+	}
+	for playerID := range game.Connections {
+		taken[playerID] = true
+	}
+	for playerID := range game.Sessions {
+		taken[playerID] = true
+	}
+	return len(taken)
+}
+
+// leaveGame detaches the connection of a player or spectator that leaves the game. A player gives
+// up the session with the seat, and may only come back as a new player.
+func leaveGame(game *model.Game, playerID model.PlayerID, spectating bool) {
+	game.Lock()
+	defer game.Unlock()
+	if spectating {
+		delete(game.Spectators, playerID)
+		return
+	}
+	delete(game.Connections, playerID)
+	delete(game.Sessions, playerID)
+}
+
+// rejectJoin sends away a new player whose join was let in while the game was starting
+func rejectJoin(game *model.Game, action *model.Action) {
+	conn := game.CopyConnections()[action.ActivePlayer]
+	leaveGame(game, action.ActivePlayer, false)
+	if conn == nil {
+		return
+	}
+	writeError(conn, model.NewError(model.ErrGameStarted, "cannot join game. game already started. spectate it instead"), action)
+	_ = conn.Close()
+}
+
+// disconnect forgets the connection of a player or spectator that is gone. A player keeps the seat
+// and the session, to rejoin, unless the player has already rejoined on another connection.
+func disconnect(game *model.Game, playerID model.PlayerID, conn model.Connection, spectating bool) {
+	game.Lock()
+	defer game.Unlock()
+	if spectating {
+		delete(game.Spectators, playerID)
+		return
+	}
+	if game.Connections[playerID] == conn {
+		game.Connections[playerID] = nil
+	}
+}
+
+// AddBot seats a bot in the game, named by the target player of the action. If the action has no
+// target player, the bot gets a free name.
+func AddBot(action *model.Action, game *model.Game, games *GameRegistry) error {
+	err := seatBot(action, game, games)
+	if err != nil {
+		return err
+	}
+	game.Actions <- action
+	return nil
+}
+
+func seatBot(action *model.Action, game *model.Game, games *GameRegistry) error {
+	snapshot := game.Snapshot()
+	game.Lock()
+	defer game.Unlock()
+	if action.TargetPlayer == "" {
+		for i := 1; ; i++ {
+			action.TargetPlayer = model.PlayerID(fmt.Sprintf("bot-%d", i))
+			// a player that has disconnected keeps the name
+			if _, taken := game.Connections[action.TargetPlayer]; !taken {
+				break
+			}
+		}
+	}
+	if _, ok := game.Connections[action.TargetPlayer]; ok {
+		return model.NewError(model.ErrNameTaken, "cannot add bot. player %s is already in the game", action.TargetPlayer)
+	}
+	if takenSeats(game, snapshot) >= game.Options.WithDefaults().MaxPlayers {
+		return model.NewError(model.ErrGameFull, "cannot add bot. too many connections")
+	}
+	// the bot gets a session no one knows, so that no one can join in its place
+	token := newSessionToken()
+	if game.Sessions == nil {
+		game.Sessions = map[model.PlayerID]string{}
+	}
+	game.Sessions[action.TargetPlayer] = token
+	if game.Log != nil {
+		logError(game, game.Log.WriteSession(model.Session{Player: action.TargetPlayer, Token: token}))
+	}
+	game.Connections[action.TargetPlayer] = newBot(action.TargetPlayer, game, games)
+	return nil
+}
+
+func newBot(playerID model.PlayerID, game *model.Game, games *GameRegistry) *bot.Bot {
+	return bot.New(playerID, games.newBotStrategy(), func(action *model.Action, state model.GameState) error {
+		// the bot can't see its own cards, but only needs to know how many it holds. The players
+		// are copied, since the bot applies its next updates to them.
+		state.Players = append([]model.Player(nil), state.Players...)
+		state.Players[0].Cards = make([]model.Card, len(state.Players[0].Knowledge))
+		err := ValidateAndCleanAction(action, &state)
+		if err != nil {
+			return err
+		}
 		game.Actions <- action
-		return game, nil
-	default:
-		//msg, _ := json.Marshal(model.Error{Err: http.StatusConflict})
-		//conn.Write(msg)
-		return nil, fmt.Errorf("invalid action: %s. not in a game", action.Type)
+		return nil
+	})
+}
+
+// RestoreGame replays an unfinished game from the store, and lets its players rejoin it.
+func RestoreGame(saved store.SavedGame, games *GameRegistry) error {
+	state, deck, record := replayGame(saved.Id, saved.Deck, saved.Actions)
+	if state.Ended {
+		// the server stopped before the log was discarded
+		return saved.Log.Close(true)
+	}
+	game := &model.Game{
+		Id:          saved.Id,
+		Options:     state.Options,
+		Connections: map[model.PlayerID]model.Connection{},
+		Spectators:  map[model.PlayerID]model.Connection{},
+		Sessions:    map[model.PlayerID]string{},
+		Actions:     games.newActions(),
+		Log:         saved.Log,
+		Publish:     games.Lobby.Update,
+		IdleTimeout: games.IdleTimeout,
+	}
+	for _, session := range saved.Sessions {
+		if !state.HasPlayer(session.Player) {
+			// the session of a player that left, or never got a seat
+			continue
+		}
+		game.Sessions[session.Player] = session.Token
+	}
+	for _, action := range saved.Actions {
+		switch action.Type {
+		case model.ActionAddBot:
+			game.Connections[action.TargetPlayer] = newBot(action.TargetPlayer, game, games)
+		case model.ActionChat:
+			addChatMessage(game, action)
+		}
+	}
+	// the bots need the state to apply updates to, while the players get it when they rejoin. It is sent
+	// before the game is registered, while no one else can be connected to it.
+	sendStateToPlayers(state, game.CopyConnections())
+	if err := games.Create(game); err != nil {
+		_ = saved.Log.Close(false)
+		return err
+	}
+	go func() {
+		runGame(game, state, deck, &record)
+		endGame(record, games)
+	}()
+	log.Info("Restored game ", game.Id, " after ", len(saved.Actions), " actions")
+	return nil
+}
+
+func replayGame(id model.GameID, deck []model.Card, actions []*model.Action) (*model.GameState, []model.Card, model.GameRecord) {
+	state := newGameState(id, deck)
+	record := newGameRecord(id, deck)
+	for _, action := range actions {
+		if action.Type == model.ActionChat {
+			// chat is not a move in the game
+			continue
+		}
+		deck = handleAction(action, &state, deck)
+		recordAction(&record, &state)
+		countAction(&state)
+	}
+	return &state, deck, record
+}
+
+// endGame unregisters a game that has ended, and exports it
+func endGame(record model.GameRecord, games *GameRegistry) {
+	games.Remove(record.Id)
+	if games.ExportDir == "" || record.Players == nil {
+		// a game that never started has nothing to replay
+		return
+	}
+	path, err := export.WriteHanabLiveReplay(games.ExportDir, record)
+	if err != nil {
+		log.Warn("failed to export game ", record.Id, ": ", err)
+		return
 	}
+	log.Info("Exported game ", record.Id, " to ", path)
+}
+
+func logError(game *model.Game, err error) {
+	if err != nil {
+		log.Error("failed to log game ", game.Id, ": ", err)
+	}
+}
+
+func newSessionToken() string {
+	return uuid.New().String()
 }
@@ -1,13 +1,15 @@
 package logic
 
 import (
-	"fmt"
-	"net"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/egoon/hanabi-server/pkg/io"
 	"github.com/egoon/hanabi-server/pkg/model"
+	"github.com/egoon/hanabi-server/pkg/store"
 )
 
 func TestConnectToGame(t *testing.T) {
@@ -16,10 +18,10 @@ func TestConnectToGame(t *testing.T) {
 		action             model.Action
 		conn               *MockConn
 		games              map[model.GameID]*model.Game
-		gameChan           chan *model.Game
 		expectedGame       *model.Game
 		expectedErr        error
 		expectedConnClosed bool
+		expectedToken      string
 	}{
 		{
 			description: "Join empty game - ok",
@@ -31,13 +33,12 @@ func TestConnectToGame(t *testing.T) {
 			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
 			games: map[model.GameID]*model.Game{"ticTacToe": {
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{},
+				Connections: map[model.PlayerID]model.Connection{},
 				Actions:     make(chan *model.Action, 5),
 			}},
-			gameChan: make(chan *model.Game, 2),
 			expectedGame: &model.Game{
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{"Top": &MockConn{}},
+				Connections: map[model.PlayerID]model.Connection{"Top": io.NewConnection(&MockConn{})},
 				Actions:     make(chan *model.Action, 5),
 			},
 		},
@@ -50,8 +51,7 @@ func TestConnectToGame(t *testing.T) {
 			},
 			conn:        &MockConn{BytesWritten: make(chan []byte, 5)},
 			games:       map[model.GameID]*model.Game{},
-			gameChan:    make(chan *model.Game, 2),
-			expectedErr: fmt.Errorf("cannot join game. game does not exist"),
+			expectedErr: model.NewError(model.ErrGameNotFound, "cannot join game. game does not exist"),
 		},
 		{
 			description: "Re-Join game - ok",
@@ -59,19 +59,54 @@ func TestConnectToGame(t *testing.T) {
 				Type:         model.ActionJoin,
 				GameID:       "ticTacToe",
 				ActivePlayer: "Top",
+				Token:        "secret",
 			},
 			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
 			games: map[model.GameID]*model.Game{"ticTacToe": {
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{"Top": &MockConn{}},
+				Connections: map[model.PlayerID]model.Connection{"Top": io.NewConnection(&MockConn{})},
+				Sessions:    map[model.PlayerID]string{"Top": "secret"},
 				Actions:     make(chan *model.Action, 5),
 			}},
-			gameChan: make(chan *model.Game, 2),
 			expectedGame: &model.Game{
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{"Top": &MockConn{}},
+				Connections: map[model.PlayerID]model.Connection{"Top": io.NewConnection(&MockConn{})},
 				Actions:     make(chan *model.Action, 5),
 			},
+			expectedToken: "secret",
+		},
+		{
+			description: "Re-Join game without token - fail",
+			action: model.Action{
+				Type:         model.ActionJoin,
+				GameID:       "ticTacToe",
+				ActivePlayer: "Top",
+			},
+			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
+			games: map[model.GameID]*model.Game{"ticTacToe": {
+				Id:          "ticTacToe",
+				Connections: map[model.PlayerID]model.Connection{"Top": io.NewConnection(&MockConn{})},
+				Sessions:    map[model.PlayerID]string{"Top": "secret"},
+				Actions:     make(chan *model.Action, 5),
+			}},
+			expectedErr: model.NewError(model.ErrInvalidSession, "cannot join game. player Top is already in the game and the session token is invalid"),
+		},
+		{
+			description: "Re-Join game with wrong token - fail",
+			action: model.Action{
+				Type:         model.ActionJoin,
+				GameID:       "ticTacToe",
+				ActivePlayer: "Top",
+				Token:        "guess",
+			},
+			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
+			games: map[model.GameID]*model.Game{"ticTacToe": {
+				Id:          "ticTacToe",
+				Connections: map[model.PlayerID]model.Connection{"Top": io.NewConnection(&MockConn{})},
+				Sessions:    map[model.PlayerID]string{"Top": "secret"},
+				Actions:     make(chan *model.Action, 5),
+			}},
+			expectedErr: model.NewError(model.ErrInvalidSession, "cannot join game. player Top is already in the game and the session token is invalid"),
 		},
 		{
 			description: "Join full game - fail",
@@ -83,11 +118,26 @@ func TestConnectToGame(t *testing.T) {
 			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
 			games: map[model.GameID]*model.Game{"ticTacToe": {
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{"Bottom": nil, "Strange": nil, "Charm": nil, "Up": nil, "Down": nil},
+				Connections: map[model.PlayerID]model.Connection{"Bottom": nil, "Strange": nil, "Charm": nil, "Up": nil, "Down": nil},
 				Actions:     make(chan *model.Action, 5),
 			}},
-			gameChan:    make(chan *model.Game, 2),
-			expectedErr: fmt.Errorf("cannot join game. too many connections"),
+			expectedErr: model.NewError(model.ErrGameFull, "cannot join game. too many connections"),
+		},
+		{
+			description: "Join game with max players reached - fail",
+			action: model.Action{
+				Type:         model.ActionJoin,
+				GameID:       "ticTacToe",
+				ActivePlayer: "Top",
+			},
+			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
+			games: map[model.GameID]*model.Game{"ticTacToe": {
+				Id:          "ticTacToe",
+				Connections: map[model.PlayerID]model.Connection{"Bottom": nil, "Strange": nil},
+				Actions:     make(chan *model.Action, 5),
+				Options:     model.GameOptions{MaxPlayers: 2},
+			}},
+			expectedErr: model.NewError(model.ErrGameFull, "cannot join game. too many connections"),
 		},
 		{
 			description: "Create game - ok",
@@ -96,12 +146,11 @@ func TestConnectToGame(t *testing.T) {
 				GameID:       "ticTacToe",
 				ActivePlayer: "Top",
 			},
-			conn:     &MockConn{BytesWritten: make(chan []byte, 5)},
-			games:    map[model.GameID]*model.Game{},
-			gameChan: make(chan *model.Game, 2),
+			conn:  &MockConn{BytesWritten: make(chan []byte, 5)},
+			games: map[model.GameID]*model.Game{},
 			expectedGame: &model.Game{
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{"Top": &MockConn{}},
+				Connections: map[model.PlayerID]model.Connection{"Top": io.NewConnection(&MockConn{})},
 				Actions:     make(chan *model.Action, 5),
 			},
 		},
@@ -115,11 +164,10 @@ func TestConnectToGame(t *testing.T) {
 			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
 			games: map[model.GameID]*model.Game{"ticTacToe": {
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{},
+				Connections: map[model.PlayerID]model.Connection{},
 				Actions:     make(chan *model.Action, 5),
 			}},
-			gameChan:    make(chan *model.Game, 2),
-			expectedErr: fmt.Errorf("cannot create game. game already exists"),
+			expectedErr: model.NewError(model.ErrGameExists, "cannot create game. game already exists"),
 		},
 		{
 			description: "Start game without joining - fail",
@@ -131,17 +179,28 @@ func TestConnectToGame(t *testing.T) {
 			conn: &MockConn{BytesWritten: make(chan []byte, 5)},
 			games: map[model.GameID]*model.Game{"ticTacToe": {
 				Id:          "ticTacToe",
-				Connections: map[model.PlayerID]net.Conn{},
+				Connections: map[model.PlayerID]model.Connection{},
 				Actions:     make(chan *model.Action, 5),
 			}},
-			gameChan:    make(chan *model.Game, 2),
-			expectedErr: fmt.Errorf("invalid action: start. not in a game"),
+			expectedErr: model.NewError(model.ErrNotInGame, "invalid action: start. not in a game"),
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.description, func(t *testing.T) {
-			game, err := ConnectToGame(&tc.action, tc.conn, tc.games, tc.gameChan)
+			games := NewGameRegistry()
+			for _, game := range tc.games {
+				assert.Nil(t, games.Create(game))
+			}
+			// the game keeps a reference to the action
+			action := tc.action
+			game, token, err := ConnectToGame(&action, io.NewConnection(tc.conn), games)
 			if err == nil {
+				assert.NotEmpty(t, token)
+				if tc.expectedToken != "" {
+					assert.Equal(t, tc.expectedToken, token)
+				}
+				assert.Empty(t, action.Token, "the token must not be passed on to the game")
+				assert.Equal(t, token, game.Sessions[action.ActivePlayer])
 				assert.Equal(t, tc.expectedGame.Id, game.Id)
 				assert.Equal(t, len(tc.expectedGame.Connections), len(game.Connections))
 				for player := range tc.expectedGame.Connections {
@@ -155,16 +214,168 @@ func TestConnectToGame(t *testing.T) {
 	}
 }
 
-func TestHandleNewGames(t *testing.T) {
-	games := map[model.GameID]*model.Game{"go": {Id: "go"}}
-	gameChan := make(chan *model.Game)
-	go HandleNewGames(games, gameChan)
+func TestConnectToGame_Spectate(t *testing.T) {
+	games := NewGameRegistry()
+	full := &model.Game{
+		Id:          "ticTacToe",
+		Connections: map[model.PlayerID]model.Connection{"Bottom": nil, "Strange": nil, "Charm": nil, "Up": nil, "Down": nil},
+		Actions:     make(chan *model.Action, 5),
+	}
+	assert.Nil(t, games.Create(full))
+
+	action := model.Action{Type: model.ActionSpectate, GameID: "ticTacToe", ActivePlayer: "Top"}
+	game, token, err := ConnectToGame(&action, io.NewConnection(&MockConn{}), games)
+	assert.Nil(t, err)
+	assert.Equal(t, full, game)
+	assert.Empty(t, token, "spectators can't reconnect as players")
+	assert.Equal(t, 5, len(game.Connections), "spectators are not players")
+	assert.Equal(t, 1, len(game.Spectators))
+	assert.NotEqual(t, model.PlayerID("Top"), action.ActivePlayer, "spectators get an id of their own")
+	_, ok := game.Spectators[action.ActivePlayer]
+	assert.True(t, ok)
+	assert.Equal(t, &action, <-game.Actions)
+
+	_, _, err = ConnectToGame(&model.Action{Type: model.ActionSpectate, GameID: "chess"}, io.NewConnection(&MockConn{}), games)
+	assert.Equal(t, model.NewError(model.ErrGameNotFound, "cannot spectate game. game does not exist"), err)
+}
+
+func TestRestoreGame(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hanabi-restore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	fileStore, err := store.NewFileStore(dir)
+	assert.Nil(t, err)
+	games := NewGameRegistry()
+	games.Store = fileStore
+
+	upConn := &MockConn{BytesWritten: make(chan []byte, 10)}
+	downConn := &MockConn{BytesWritten: make(chan []byte, 10)}
+	game, upToken, err := ConnectToGame(&model.Action{Type: model.ActionCreate, GameID: "saved", ActivePlayer: "Up"}, io.NewConnection(upConn), games)
+	assert.Nil(t, err)
+	_, downToken, err := ConnectToGame(&model.Action{Type: model.ActionJoin, GameID: "saved", ActivePlayer: "Down"}, io.NewConnection(downConn), games)
+	assert.Nil(t, err)
+	game.Actions <- &model.Action{Type: model.ActionStart, ActivePlayer: "Up"}
+	game.Actions <- &model.Action{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "1", Card: []int{}}
+	// create, join, start and clue
+	before := model.GameState{}
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, applyMessage(&before, <-upConn.BytesWritten))
+	}
+
+	// the server restarts
+	saved, err := fileStore.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(saved))
+	restoredGames := NewGameRegistry()
+	restoredGames.Store = fileStore
+	assert.Nil(t, RestoreGame(saved[0], restoredGames))
+	restored, ok := restoredGames.Get("saved")
+	assert.True(t, ok)
+	assert.Equal(t, map[model.PlayerID]string{"Up": upToken, "Down": downToken}, restored.Sessions)
+
+	rejoinConn := &MockConn{BytesWritten: make(chan []byte, 10)}
+	_, token, err := ConnectToGame(&model.Action{Type: model.ActionJoin, GameID: "saved", ActivePlayer: "Up", Token: upToken}, io.NewConnection(rejoinConn), restoredGames)
+	assert.Nil(t, err)
+	assert.Equal(t, upToken, token)
+	after := model.GameState{}
+	assert.Nil(t, decodePayload(<-rejoinConn.BytesWritten, &after))
+	assert.Equal(t, model.Action{Type: model.ActionJoin, GameID: "saved", ActivePlayer: "Up"}, after.PlayedAction)
+	assert.Equal(t, before.Seq+1, after.Seq, "the numbers go on after the restart")
+	after.PlayedAction = before.PlayedAction
+	after.Seq = before.Seq
+	assert.Equal(t, before, after)
+}
 
-	gameChan <- &model.Game{Id: "chess"}
-	goConn := &MockConn{}
-	gameChan <- &model.Game{Id: "go", Connections: map[model.PlayerID]net.Conn{"top": goConn}}
-	gameChan <- &model.Game{Id: "chess"} //flush
+func TestRestoreGame_Unstarted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hanabi-restore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	fileStore, err := store.NewFileStore(dir)
+	assert.Nil(t, err)
+	games := NewGameRegistry()
+	games.Store = fileStore
+
+	upConn := &MockConn{BytesWritten: make(chan []byte, 10)}
+	game, upToken, err := ConnectToGame(&model.Action{Type: model.ActionCreate, GameID: "saved", ActivePlayer: "Up", Options: &model.GameOptions{MaxPlayers: 3}}, io.NewConnection(upConn), games)
+	assert.Nil(t, err)
+	for _, player := range []model.PlayerID{"Down", "Gone"} {
+		_, _, err = ConnectToGame(&model.Action{Type: model.ActionJoin, GameID: "saved", ActivePlayer: player}, io.NewConnection(&MockConn{BytesWritten: make(chan []byte, 10)}), games)
+		assert.Nil(t, err)
+	}
+	leaveGame(game, "Gone", false)
+	game.Actions <- &model.Action{Type: model.ActionLeave, ActivePlayer: "Gone"}
+	// create, two joins and the leave
+	for i := 0; i < 4; i++ {
+		<-upConn.BytesWritten
+	}
+
+	// the server restarts, and only the seated players may rejoin
+	saved, err := fileStore.Load()
+	assert.Nil(t, err)
+	restoredGames := NewGameRegistry()
+	assert.Nil(t, RestoreGame(saved[0], restoredGames))
+	restored, _ := restoredGames.Get("saved")
+	assert.Equal(t, upToken, restored.Sessions["Up"])
+	assert.Equal(t, 2, len(restored.Sessions), "Gone left before the restart")
+
+	// the seats of the players that have not rejoined are taken
+	_, _, err = ConnectToGame(&model.Action{Type: model.ActionJoin, GameID: "saved", ActivePlayer: "New"}, io.NewConnection(&MockConn{BytesWritten: make(chan []byte, 10)}), restoredGames)
+	assert.Nil(t, err)
+	_, _, err = ConnectToGame(&model.Action{Type: model.ActionJoin, GameID: "saved", ActivePlayer: "Late"}, io.NewConnection(&MockConn{BytesWritten: make(chan []byte, 10)}), restoredGames)
+	assert.Equal(t, model.NewError(model.ErrGameFull, "cannot join game. too many connections"), err)
+}
+
+func TestConnectToGame_Seed(t *testing.T) {
+	games := NewGameRegistry()
+	for _, seed := range []*int64{seedOf(0), seedOf(42), nil} {
+		conn := &MockConn{BytesWritten: make(chan []byte, 10)}
+		action := &model.Action{Type: model.ActionCreate, GameID: "seeded", ActivePlayer: "Up", Seed: seed}
+		game, _, err := ConnectToGame(action, io.NewConnection(conn), games)
+		assert.Nil(t, err)
+		<-conn.BytesWritten
+
+		snapshot := game.Snapshot()
+		if assert.NotNil(t, snapshot.Seed, "the server picks a seed if it is not set") && seed != nil {
+			assert.Equal(t, *seed, *snapshot.Seed)
+		}
+		assert.Nil(t, snapshot.PlayedAction.Seed, "the seed is kept out of the played action")
+		game.Actions <- &model.Action{Type: model.ActionLeave, ActivePlayer: "Up"}
+		waitForGames(games, 0)
+	}
+}
+
+func TestAddBot(t *testing.T) {
+	games := NewGameRegistry()
+	upConn := &MockConn{BytesWritten: make(chan []byte, 10)}
+	game, _, err := ConnectToGame(&model.Action{Type: model.ActionCreate, GameID: "bots", ActivePlayer: "Up", Seed: seedOf(1)}, io.NewConnection(upConn), games)
+	assert.Nil(t, err)
+
+	action := &model.Action{Type: model.ActionAddBot, ActivePlayer: "Up"}
+	assert.Nil(t, AddBot(action, game, games))
+	assert.Equal(t, model.PlayerID("bot-1"), action.TargetPlayer, "the server names unnamed bots")
+	assert.Nil(t, AddBot(&model.Action{Type: model.ActionAddBot, ActivePlayer: "Up", TargetPlayer: "Robot"}, game, games))
+	err = AddBot(&model.Action{Type: model.ActionAddBot, ActivePlayer: "Up", TargetPlayer: "Robot"}, game, games)
+	assert.Equal(t, model.NewError(model.ErrNameTaken, "cannot add bot. player Robot is already in the game"), err)
+	_, _, err = ConnectToGame(&model.Action{Type: model.ActionJoin, GameID: "bots", ActivePlayer: "Robot"}, io.NewConnection(&MockConn{}), games)
+	assert.NotNil(t, err, "no one may take the seat of a bot")
+
+	game.Actions <- &model.Action{Type: model.ActionStart, ActivePlayer: "Up"}
+	state := model.GameState{}
+	readState := func() model.GameState {
+		assert.Nil(t, applyMessage(&state, <-upConn.BytesWritten))
+		return state
+	}
+	// create, the bots and start
+	for i := 0; i < 3; i++ {
+		readState()
+	}
+	started := readState()
+	assert.Equal(t, []model.PlayerID{"Up", "bot-1", "Robot"}, []model.PlayerID{started.Players[0].Id, started.Players[1].Id, started.Players[2].Id})
 
-	assert.Equal(t, 2, len(games))
-	assert.True(t, goConn.Closed)
+	game.Actions <- &model.Action{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{0}}
+	assert.Equal(t, model.PlayerID("Up"), readState().PlayedAction.ActivePlayer)
+	assert.Equal(t, model.PlayerID("bot-1"), readState().PlayedAction.ActivePlayer, "the bots play their turns")
+	robotPlayed := readState()
+	assert.Equal(t, model.PlayerID("Robot"), robotPlayed.PlayedAction.ActivePlayer)
+	assert.Equal(t, model.PlayerID("Up"), robotPlayed.Players[0].Id)
 }
@@ -0,0 +1,139 @@
+package logic
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// Lobby keeps the summaries of all games, and sends the list of games to subscribed connections
+// every time a game changes. It is safe for concurrent use. The lists are sent by a goroutine for
+// each subscriber, so that a slow connection never holds up the games.
+type Lobby struct {
+	mu          sync.Mutex
+	games       map[model.GameID]model.GameSummary
+	subscribers map[model.Connection]*subscriber
+}
+
+// LobbyWriteTimeout is how long Unsubscribe waits for a list that is being written. A connection
+// that has not taken the list by then is closed, since it is not reading.
+var LobbyWriteTimeout = 10 * time.Second
+
+// subscriber holds the latest list of games that has not been sent to a connection yet
+type subscriber struct {
+	latest  chan model.Lobby
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// offer replaces the list that is waiting to be sent, if any, since a list is out of date once
+// there is a newer one. It must only be called with the lock of the lobby held.
+func (s *subscriber) offer(lobby model.Lobby) {
+	select {
+	case <-s.latest:
+	default:
+	}
+	s.latest <- lobby
+}
+
+func NewLobby() *Lobby {
+	return &Lobby{
+		games:       map[model.GameID]model.GameSummary{},
+		subscribers: map[model.Connection]*subscriber{},
+	}
+}
+
+// Update changes the summary of a game, and sends the new list to the subscribers. An ended game
+// is sent once, and then dropped from the list.
+func (l *Lobby) Update(summary model.GameSummary) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.games[summary.Id] = summary
+	lobby := l.list()
+	if summary.Ended {
+		delete(l.games, summary.Id)
+	}
+	for _, s := range l.subscribers {
+		s.offer(lobby)
+	}
+}
+
+// List returns all games, sorted by id
+func (l *Lobby) List() model.Lobby {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.list()
+}
+
+func (l *Lobby) list() model.Lobby {
+	lobby := model.Lobby{Games: make([]model.GameSummary, 0, len(l.games))}
+	for _, summary := range l.games {
+		lobby.Games = append(lobby.Games, summary)
+	}
+	sort.Slice(lobby.Games, func(i, j int) bool { return lobby.Games[i].Id < lobby.Games[j].Id })
+	return lobby
+}
+
+// Subscribe sends the list of games to the connection, and then every change to it
+func (l *Lobby) Subscribe(conn model.Connection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.subscribers[conn]; ok {
+		s.offer(l.list())
+		return
+	}
+	s := &subscriber{
+		latest:  make(chan model.Lobby, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	s.offer(l.list())
+	l.subscribers[conn] = s
+	go l.send(conn, s)
+}
+
+// send writes the lists to the connection until it is unsubscribed, or a write fails
+func (l *Lobby) send(conn model.Connection, s *subscriber) {
+	defer close(s.stopped)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case lobby := <-s.latest:
+			_, err := conn.Write(lobby)
+			if err != nil {
+				log.Warn("failed to send lobby, unsubscribing: ", err)
+				l.mu.Lock()
+				if l.subscribers[conn] == s {
+					delete(l.subscribers, conn)
+				}
+				l.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// Unsubscribe stops sending changes to the connection. Nothing more is written to the connection
+// once it returns. If a write is blocked for longer than LobbyWriteTimeout, the connection is
+// closed. Unsubscribing an unknown connection is a no-op.
+func (l *Lobby) Unsubscribe(conn model.Connection) {
+	l.mu.Lock()
+	s, ok := l.subscribers[conn]
+	delete(l.subscribers, conn)
+	l.mu.Unlock()
+	if !ok {
+		return
+	}
+	close(s.stop)
+	select {
+	case <-s.stopped:
+	case <-time.After(LobbyWriteTimeout):
+		log.Warn("lobby write blocked for ", LobbyWriteTimeout, ", closing connection")
+		_ = conn.Close()
+		<-s.stopped
+	}
+}
@@ -0,0 +1,152 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestLobby(t *testing.T) {
+	lobby := NewLobby()
+	conn := NewMockConnection()
+	readLobby := func() model.Lobby {
+		l := model.Lobby{}
+		assert.Nil(t, decodePayload(<-conn.Messages, &l))
+		return l
+	}
+	chess := model.GameSummary{Id: "chess", Players: 1, MaxPlayers: 2}
+	lobby.Update(chess)
+
+	lobby.Subscribe(conn)
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{chess}}, readLobby())
+
+	goGame := model.GameSummary{Id: "go", Players: 1, MaxPlayers: 5, Variant: model.VariantRainbow}
+	lobby.Update(goGame)
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{chess, goGame}}, readLobby())
+
+	chess.Started = true
+	chess.Ended = true
+	lobby.Update(chess)
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{chess, goGame}}, readLobby(), "ended games are shown once")
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{goGame}}, lobby.List())
+
+	lobby.Unsubscribe(conn)
+	lobby.Update(model.GameSummary{Id: "checkers"})
+	assert.Equal(t, 0, len(conn.Messages))
+}
+
+func TestLobby_SlowSubscriber(t *testing.T) {
+	lobby := NewLobby()
+	// a connection that does not read, so the first write blocks until the test reads
+	slow := NewMockConnection()
+	slow.Messages = make(chan []byte)
+	lobby.Subscribe(slow)
+
+	updated := make(chan struct{})
+	go func() {
+		for i := 1; i <= 100; i++ {
+			lobby.Update(model.GameSummary{Id: "go", Players: i % 5})
+		}
+		close(updated)
+	}()
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("a slow subscriber held up the games")
+	}
+
+	// once the connection reads, it gets the list it was blocked on, unless that was the latest,
+	// and then the latest list
+	latest := model.Lobby{Games: []model.GameSummary{{Id: "go", Players: 0}}}
+	l := model.Lobby{}
+	assert.Nil(t, decodePayload(<-slow.Messages, &l))
+	if !assert.ObjectsAreEqual(latest, l) {
+		assert.Nil(t, decodePayload(<-slow.Messages, &l))
+	}
+	assert.Equal(t, latest, l)
+	lobby.Unsubscribe(slow)
+}
+
+func TestLobby_Unsubscribe_BlockedWrite(t *testing.T) {
+	defer func(timeout time.Duration) { LobbyWriteTimeout = timeout }(LobbyWriteTimeout)
+	LobbyWriteTimeout = 10 * time.Millisecond
+	lobby := NewLobby()
+	// a connection that never reads, so the write of the first list blocks
+	blocked := &blockingConnection{MockConnection: NewMockConnection(), writing: make(chan struct{}, 1)}
+	blocked.Messages = make(chan []byte)
+	lobby.Subscribe(blocked)
+	<-blocked.writing
+
+	unsubscribed := make(chan struct{})
+	go func() {
+		lobby.Unsubscribe(blocked)
+		close(unsubscribed)
+	}()
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("a blocked write held up unsubscribe")
+	}
+	_, err := blocked.ReadAction()
+	assert.NotNil(t, err, "the blocked connection is closed")
+}
+
+func TestHandleConnection_Lobby(t *testing.T) {
+	games := NewGameRegistry()
+	subscriber := NewMockConnection()
+	go HandleConnection(subscriber, games)
+	readLobby := func(conn *MockConnection) model.Lobby {
+		l := model.Lobby{}
+		assert.Nil(t, decodePayload(<-conn.Messages, &l))
+		return l
+	}
+
+	subscriber.Actions <- &model.Action{Type: model.ActionSubscribeLobby}
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{}}, readLobby(subscriber))
+
+	creator := NewMockConnection()
+	go HandleConnection(creator, games)
+	creator.Actions <- &model.Action{Type: model.ActionCreate, GameID: "go", ActivePlayer: "Up", Options: &model.GameOptions{MaxPlayers: 2}}
+	created := model.GameSummary{Id: "go", Players: 1, MaxPlayers: 2}
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{created}}, readLobby(subscriber))
+
+	lister := NewMockConnection()
+	go HandleConnection(lister, games)
+	lister.Actions <- &model.Action{Type: model.ActionListGames}
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{created}}, readLobby(lister))
+
+	// joining the game stops the lobby
+	subscriber.Actions <- &model.Action{Type: model.ActionJoin, GameID: "go", ActivePlayer: "Down"}
+	reply := model.GameState{}
+	assert.Nil(t, decodePayload(<-subscriber.Messages, &reply))
+	assert.Equal(t, model.GameID("go"), reply.Id)
+	joined := model.GameState{}
+	assert.Nil(t, decodePayload(<-subscriber.Messages, &joined))
+	assert.Equal(t, model.ActionJoin, joined.PlayedAction.Type)
+
+	creator.Actions <- &model.Action{Type: model.ActionStart}
+	started := model.Update{}
+	assert.Nil(t, decodePayload(<-subscriber.Messages, &started))
+	assert.Equal(t, model.ActionStart, started.Action.Type)
+
+	lister.Actions <- &model.Action{Type: model.ActionListGames}
+	created.Players = 2
+	created.Started = true
+	assert.Equal(t, model.Lobby{Games: []model.GameSummary{created}}, readLobby(lister))
+	assert.Equal(t, 0, len(subscriber.Messages))
+	assert.Equal(t, 0, len(lister.Messages), "only subscribers are sent changes")
+}
+
+// blockingConnection tells when a write has started
+type blockingConnection struct {
+	*MockConnection
+	writing chan struct{}
+}
+
+func (c *blockingConnection) Write(v interface{}) (int, error) {
+	c.writing <- struct{}{}
+	return c.MockConnection.Write(v)
+}
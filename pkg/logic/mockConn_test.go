@@ -1,12 +1,58 @@
 package logic
 
 import (
+	"encoding/json"
+	"fmt"
 	"net"
+	"sync"
+	"testing"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/io"
+	"github.com/egoon/hanabi-server/pkg/model"
 )
 
+// decodePayload reads a message that an io connection wrote to a MockConn
+func decodePayload(msg []byte, v interface{}) error {
+	envelope, err := io.Decode(msg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(envelope.Payload, v)
+}
+
+// payload returns the JSON payload of a message, to compare it as a string
+func payload(t *testing.T, msg []byte) string {
+	envelope, err := io.Decode(msg)
+	assert.Nil(t, err)
+	return string(envelope.Payload)
+}
+
+// applyMessage changes the view a client has of the game, by a state or an update that was sent to it
+func applyMessage(view *model.GameState, msg []byte) error {
+	envelope, err := io.Decode(msg)
+	if err != nil {
+		return err
+	}
+	switch envelope.Type {
+	case model.MessageState:
+		*view = model.GameState{}
+		return json.Unmarshal(envelope.Payload, view)
+	case model.MessageUpdate:
+		update := model.Update{}
+		err = json.Unmarshal(envelope.Payload, &update)
+		if err != nil {
+			return err
+		}
+		view.Apply(update)
+		return nil
+	}
+	return fmt.Errorf("expected a state or an update, got %s", envelope.Type)
+}
+
 type MockConn struct {
 	BytesToRead        []byte
 	BytesWritten       chan []byte
@@ -62,3 +108,51 @@ func (m *MockConn) SetWriteDeadline(t time.Time) error {
 	m.WriteDeadLine = t
 	return m.WriteDeadlineError
 }
+
+// MockConnection is a model.Connection that reads actions from a channel, and writes each
+// message in an envelope to another
+type MockConnection struct {
+	Actions  chan *model.Action
+	Messages chan []byte
+	closed   chan struct{}
+	once     sync.Once
+	lock     sync.Mutex
+	encoder  io.Encoder
+}
+
+func NewMockConnection() *MockConnection {
+	return &MockConnection{
+		Actions:  make(chan *model.Action, 10),
+		Messages: make(chan []byte, 10),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (m *MockConnection) ReadAction() (*model.Action, error) {
+	select {
+	case action := <-m.Actions:
+		return action, nil
+	case <-m.closed:
+		return nil, fmt.Errorf("connection closed")
+	}
+}
+
+func (m *MockConnection) Write(v interface{}) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	bytes, err := m.encoder.Encode(v)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case m.Messages <- bytes:
+		return len(bytes), nil
+	case <-m.closed:
+		return 0, fmt.Errorf("connection closed")
+	}
+}
+
+func (m *MockConnection) Close() error {
+	m.once.Do(func() { close(m.closed) })
+	return nil
+}
@@ -0,0 +1,128 @@
+package logic
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/egoon/hanabi-server/pkg/bot"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+const defaultActionBuffer = 5
+
+var errShuttingDown = fmt.Errorf("server is shutting down")
+
+// GameStore persists games, so that they can be restored after a restart of the server.
+type GameStore interface {
+	// Create fails with an error satisfying os.IsExist if the game is already stored
+	Create(id model.GameID) (model.GameLog, error)
+}
+
+// GameRegistry keeps track of all running games. It is safe for concurrent use.
+type GameRegistry struct {
+	mu    sync.RWMutex
+	games map[model.GameID]*model.Game
+	// Lobby shows the registered games to clients that are not in a game
+	Lobby *Lobby
+	// Store is optional, and must be set before the registry is used
+	Store GameStore
+	// ExportDir is optional. Ended games are written there as hanab.live replays.
+	ExportDir string
+	// BotStrategy is optional, and returns the strategy of each added bot. Bots play bot.Cautious by default.
+	BotStrategy func() bot.Strategy
+	// ActionBuffer is optional, and is the number of actions that may be queued for each game. Unset means 5.
+	ActionBuffer int
+	// IdleTimeout is optional. Games that no player or spectator has been connected to for this long are abandoned.
+	IdleTimeout time.Duration
+	// closed registries accept no new games
+	closed bool
+}
+
+func NewGameRegistry() *GameRegistry {
+	return &GameRegistry{
+		games: map[model.GameID]*model.Game{},
+		Lobby: NewLobby(),
+	}
+}
+
+// Create registers a new game. It fails if a game with the same id is already registered.
+func (r *GameRegistry) Create(game *model.Game) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errShuttingDown
+	}
+	if _, ok := r.games[game.Id]; ok {
+		return fmt.Errorf("game %s already exists", game.Id)
+	}
+	r.games[game.Id] = game
+	return nil
+}
+
+// Close stops the registry from accepting new games. The registered games keep running.
+func (r *GameRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+}
+
+// Get returns the game with the given id, if it is registered.
+func (r *GameRegistry) Get(id model.GameID) (*model.Game, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	game, ok := r.games[id]
+	return game, ok
+}
+
+// List returns the ids of all registered games, sorted.
+func (r *GameRegistry) List() []model.GameID {
+	r.mu.RLock()
+	ids := make([]model.GameID, 0, len(r.games))
+	for id := range r.games {
+		ids = append(ids, id)
+	}
+	r.mu.RUnlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Remove unregisters the game with the given id. Removing an absent game is a no-op.
+func (r *GameRegistry) Remove(id model.GameID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.games, id)
+}
+
+// Snapshot returns the games registered at the time of the call. The registry may
+// change while the caller iterates over the result.
+func (r *GameRegistry) Snapshot() []*model.Game {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	games := make([]*model.Game, 0, len(r.games))
+	for _, game := range r.games {
+		games = append(games, game)
+	}
+	return games
+}
+
+func (r *GameRegistry) newActions() chan *model.Action {
+	if r.ActionBuffer == 0 {
+		return make(chan *model.Action, defaultActionBuffer)
+	}
+	return make(chan *model.Action, r.ActionBuffer)
+}
+
+func (r *GameRegistry) newBotStrategy() bot.Strategy {
+	if r.BotStrategy == nil {
+		return bot.Cautious{}
+	}
+	return r.BotStrategy()
+}
+
+func (r *GameRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.games)
+}
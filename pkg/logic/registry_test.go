@@ -0,0 +1,86 @@
+package logic
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestGameRegistry(t *testing.T) {
+	games := NewGameRegistry()
+
+	assert.Nil(t, games.Create(&model.Game{Id: "go"}))
+	assert.Nil(t, games.Create(&model.Game{Id: "chess"}))
+	assert.Equal(t, fmt.Errorf("game go already exists"), games.Create(&model.Game{Id: "go"}))
+
+	game, ok := games.Get("go")
+	assert.True(t, ok)
+	assert.Equal(t, model.GameID("go"), game.Id)
+	_, ok = games.Get("checkers")
+	assert.False(t, ok)
+
+	assert.Equal(t, []model.GameID{"chess", "go"}, games.List())
+	assert.Equal(t, 2, len(games.Snapshot()))
+
+	games.Remove("go")
+	games.Remove("checkers")
+	_, ok = games.Get("go")
+	assert.False(t, ok)
+	assert.Equal(t, []model.GameID{"chess"}, games.List())
+	assert.Equal(t, 1, games.Len())
+
+	games.Close()
+	assert.Equal(t, fmt.Errorf("server is shutting down"), games.Create(&model.Game{Id: "go"}))
+	assert.Equal(t, 1, games.Len(), "running games stay registered")
+}
+
+// run with -race to detect unsynchronized access
+func TestGameRegistry_Concurrent(t *testing.T) {
+	games := NewGameRegistry()
+	const workers = 20
+	created := make(chan model.GameID, workers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every two workers compete for the same id
+			id := model.GameID(fmt.Sprintf("game%d", i/2))
+			if games.Create(&model.Game{Id: id}) == nil {
+				created <- id
+			}
+			games.Get(id)
+			games.List()
+			for _, game := range games.Snapshot() {
+				_ = game.Id
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(created)
+
+	ids := map[model.GameID]bool{}
+	for id := range created {
+		assert.False(t, ids[id], "game %s created twice", id)
+		ids[id] = true
+	}
+	assert.Equal(t, workers/2, len(ids))
+	assert.Equal(t, workers/2, games.Len())
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := model.GameID(fmt.Sprintf("game%d", i/2))
+			games.Remove(id)
+			games.Get(id)
+			games.List()
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 0, games.Len())
+}
@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/egoon/hanabi-server/pkg/bot"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// SimulateGame plays a whole game between strategies, one for each seat, without any connections.
+// It returns the state when the game ended, and the number of turns that were played. It fails
+// if a strategy takes an action that breaks the rules.
+func SimulateGame(deck []model.Card, options model.GameOptions, strategies []bot.Strategy) (model.GameState, int, error) {
+	err := validateOptions(&options)
+	if err != nil {
+		return model.GameState{}, 0, err
+	}
+	if len(strategies) == 0 {
+		return model.GameState{}, 0, fmt.Errorf("too few players")
+	}
+	if len(strategies) > options.WithDefaults().MaxPlayers {
+		return model.GameState{}, 0, fmt.Errorf("too many players")
+	}
+	state := newGameState("simulation", deck)
+	seats := map[model.PlayerID]bot.Strategy{}
+	for i, strategy := range strategies {
+		playerID := model.PlayerID(fmt.Sprintf("bot-%d", i+1))
+		seats[playerID] = strategy
+		action := &model.Action{Type: model.ActionJoin, ActivePlayer: playerID}
+		if i == 0 {
+			action = &model.Action{Type: model.ActionCreate, ActivePlayer: playerID, Options: &options}
+		}
+		deck = handleAction(action, &state, deck)
+	}
+	start := &model.Action{Type: model.ActionStart, ActivePlayer: state.Players[0].Id}
+	err = ValidateAndCleanAction(start, &state)
+	if err != nil {
+		return state, 0, err
+	}
+	deck = handleAction(start, &state, deck)
+	turns := 0
+	for !state.Ended {
+		playerID := state.Players[0].Id
+		view, _ := state.ForPlayer(playerID)
+		action := seats[playerID].NextAction(view)
+		action.ActivePlayer = playerID
+		switch action.Type {
+		case model.ActionClue, model.ActionPlay, model.ActionDiscard:
+		default:
+			return state, turns, fmt.Errorf("%s took a %s action on its turn", playerID, action.Type)
+		}
+		err = ValidateAndCleanAction(&action, &state)
+		if err != nil {
+			return state, turns, fmt.Errorf("%s failed to %s: %w", playerID, action.Type, err)
+		}
+		deck = handleAction(&action, &state, deck)
+		turns++
+	}
+	return state, turns, nil
+}
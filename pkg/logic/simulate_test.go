@@ -0,0 +1,88 @@
+package logic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/bot"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+type playFirstCard struct{}
+
+func (playFirstCard) NextAction(state model.GameState) model.Action {
+	return model.Action{Type: model.ActionPlay, Card: []int{0}}
+}
+
+type discardFirstCard struct{}
+
+func (discardFirstCard) NextAction(state model.GameState) model.Action {
+	return model.Action{Type: model.ActionDiscard, Card: []int{0}}
+}
+
+type startAgain struct{}
+
+func (startAgain) NextAction(state model.GameState) model.Action {
+	return model.Action{Type: model.ActionStart}
+}
+
+func TestSimulateGame(t *testing.T) {
+	for players := 2; players <= 5; players++ {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			strategies := make([]bot.Strategy, players)
+			for i := range strategies {
+				strategies[i] = bot.Cautious{}
+			}
+			state, turns, err := SimulateGame(model.CreateDeck(model.VariantStandard, 42), model.GameOptions{}, strategies)
+			assert.Nil(t, err)
+			assert.True(t, state.Ended)
+			assert.Equal(t, players, len(state.Players))
+			assert.True(t, turns > 0)
+			assert.True(t, state.Lives > 0, "a cautious strategy only plays cards it knows are playable")
+
+			again, againTurns, _ := SimulateGame(model.CreateDeck(model.VariantStandard, 42), model.GameOptions{}, strategies)
+			assert.Equal(t, state, again, "the same deck must give the same game")
+			assert.Equal(t, turns, againTurns)
+		})
+	}
+}
+
+func TestSimulateGame_StrikeOut(t *testing.T) {
+	deck := []model.Card{b5, b4, b3, b2, b5, b4, b3, b2, b1, b1}
+	state, turns, err := SimulateGame(deck, model.GameOptions{HandSize: 2}, []bot.Strategy{playFirstCard{}, playFirstCard{}})
+	assert.Nil(t, err)
+	assert.True(t, state.Ended)
+	assert.Equal(t, 0, state.Lives)
+	assert.Equal(t, 3, turns)
+}
+
+func TestSimulateGame_FinalRound(t *testing.T) {
+	// six cards are dealt, and the last card is drawn on the fourth turn
+	deck := []model.Card{b1, b2, b3, b4, b5, w1, w2, w3, w4, w5}
+	strategies := []bot.Strategy{discardFirstCard{}, discardFirstCard{}, discardFirstCard{}}
+
+	state, turns, err := SimulateGame(deck, model.GameOptions{HandSize: 2}, strategies)
+	assert.Nil(t, err)
+	assert.True(t, state.Ended)
+	assert.Equal(t, 7, turns, "every player has one more turn")
+	assert.Equal(t, 7, state.Turn)
+	for _, player := range state.Players {
+		assert.Equal(t, 1, len(player.Cards), "%s discarded without drawing on its final turn", player.Id)
+	}
+
+	state, turns, err = SimulateGame(deck, model.GameOptions{HandSize: 2, FullFinalRound: true}, strategies)
+	assert.Nil(t, err)
+	assert.True(t, state.Ended)
+	assert.Equal(t, 9, turns, "the round is played to the end, and then one more round")
+	assert.Equal(t, 0, state.Turn%len(strategies))
+}
+
+func TestSimulateGame_Fail(t *testing.T) {
+	_, _, err := SimulateGame(model.CreateDeck(model.VariantStandard, 42), model.GameOptions{}, []bot.Strategy{playFirstCard{}})
+	assert.Equal(t, model.NewError(model.ErrTooFewPlayers, "too few players"), err)
+
+	_, _, err = SimulateGame(model.CreateDeck(model.VariantStandard, 42), model.GameOptions{}, []bot.Strategy{startAgain{}, startAgain{}})
+	assert.Equal(t, fmt.Errorf("bot-1 took a start action on its turn"), err)
+}
@@ -0,0 +1,152 @@
+package logic
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// the number of updates a game keeps, to send them again to clients that missed them
+const updateHistorySize = 50
+
+// pileSizes are the sizes of the table, the discards and the deck before an action, to tell which
+// card the action added, and whether a card was drawn
+type pileSizes struct {
+	table    int
+	discards int
+	deck     int
+}
+
+func pileSizesOf(state *model.GameState) pileSizes {
+	return pileSizes{table: len(state.Table), discards: len(state.Discards), deck: state.Deck}
+}
+
+// newUpdate describes what the last action changed, as spectators see it. The update shares no
+// cards with the state, which keeps changing.
+func newUpdate(state *model.GameState, before pileSizes) model.Update {
+	view := state.ForSpectator()
+	action := view.PlayedAction
+	update := model.Update{
+		Seq:            view.Seq,
+		Action:         action,
+		Clues:          view.Clues,
+		Lives:          view.Lives,
+		Deck:           view.Deck,
+		Turn:           view.Turn,
+		TurnsRemaining: view.TurnsRemaining,
+		Started:        view.Started,
+		Ended:          view.Ended,
+		Seed:           view.Seed,
+		Clock:          view.Clock,
+	}
+	if len(view.Table) > before.table {
+		card := view.Table[len(view.Table)-1]
+		update.Table = &card
+	}
+	if len(view.Discards) > before.discards {
+		card := view.Discards[len(view.Discards)-1]
+		update.Discarded = &card
+	}
+	switch action.Type {
+	case model.ActionPlay, model.ActionDiscard:
+		// the players have been rotated, so the player that drew is last
+		drawer := view.Players[len(view.Players)-1]
+		if before.deck > 0 && action.Card[0] < len(drawer.Cards) {
+			card := drawer.Cards[action.Card[0]]
+			update.Drawn = &card
+		}
+	case model.ActionCreate, model.ActionJoin, model.ActionAddBot, model.ActionLeave, model.ActionStart:
+		update.Players = copyPlayers(view.Players)
+	}
+	return update
+}
+
+func copyPlayers(players []model.Player) []model.Player {
+	copied := make([]model.Player, len(players))
+	for i, player := range players {
+		copied[i] = model.Player{
+			Id:        player.Id,
+			Cards:     append([]model.Card(nil), player.Cards...),
+			Knowledge: append([]model.CardKnowledge(nil), player.Knowledge...),
+		}
+	}
+	return copied
+}
+
+// sendUpdates sends every player and spectator the update, as they see it
+func sendUpdates(update model.Update, connections, spectators map[model.PlayerID]model.Connection) {
+	for playerID, conn := range connections {
+		if conn == nil {
+			continue
+		}
+		_, err := conn.Write(update.ForPlayer(playerID))
+		if err != nil {
+			log.Error("failed to write update to player")
+		}
+	}
+	for _, conn := range spectators {
+		if conn == nil {
+			continue
+		}
+		_, err := conn.Write(update)
+		if err != nil {
+			log.Error("failed to write update to spectator")
+		}
+	}
+}
+
+// updateHistory keeps the latest updates of a game, as spectators see them
+type updateHistory struct {
+	updates []model.Update
+}
+
+func (h *updateHistory) add(update model.Update) {
+	if len(h.updates) == updateHistorySize {
+		h.updates = h.updates[1:]
+	}
+	h.updates = append(h.updates, update)
+}
+
+// since returns the updates after the one numbered seq, up to the current one. It returns false
+// if some of them are no longer kept.
+func (h *updateHistory) since(seq int64, current int64) ([]model.Update, bool) {
+	missed := current - seq
+	if missed < 0 || missed > int64(len(h.updates)) {
+		return nil, false
+	}
+	return h.updates[len(h.updates)-int(missed):], true
+}
+
+// resync sends the client that asked the updates it missed, or the whole state if they are no longer kept
+func resync(state *model.GameState, history *updateHistory, action *model.Action, connections, spectators map[model.PlayerID]model.Connection) {
+	conn, spectating := connections[action.ActivePlayer], false
+	if conn == nil {
+		conn, spectating = spectators[action.ActivePlayer], true
+	}
+	if conn == nil {
+		return
+	}
+	messages := []interface{}{}
+	updates, ok := history.since(action.Since, state.Seq)
+	switch {
+	case !ok && spectating:
+		messages = append(messages, state.ForSpectator())
+	case !ok:
+		playerState, _ := state.ForPlayer(action.ActivePlayer)
+		messages = append(messages, playerState)
+	default:
+		for _, update := range updates {
+			if !spectating {
+				update = update.ForPlayer(action.ActivePlayer)
+			}
+			messages = append(messages, update)
+		}
+	}
+	for _, message := range messages {
+		_, err := conn.Write(message)
+		if err != nil {
+			log.Error("failed to resync ", action.ActivePlayer)
+			return
+		}
+	}
+}
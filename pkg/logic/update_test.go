@@ -0,0 +1,178 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/bot"
+	"github.com/egoon/hanabi-server/pkg/io"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// roundTrip returns what a client reads from a message
+func roundTrip(t *testing.T, message interface{}, v interface{}) {
+	bytes, err := json.Marshal(message)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(bytes, v))
+}
+
+func TestNewUpdate_Apply(t *testing.T) {
+	for players := 2; players <= 5; players++ {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			deck := model.CreateDeck(model.VariantRainbow, int64(players))
+			state := newGameState("game", deck)
+			deck = handleAction(&model.Action{Type: model.ActionCreate, ActivePlayer: "bot-1", Options: &model.GameOptions{Variant: model.VariantRainbow}}, &state, deck)
+			actions := make([]*model.Action, 0, players)
+			for i := 2; i <= players; i++ {
+				actions = append(actions, &model.Action{Type: model.ActionJoin, ActivePlayer: model.PlayerID(fmt.Sprintf("bot-%d", i))})
+			}
+			actions = append(actions, &model.Action{Type: model.ActionStart, ActivePlayer: "bot-1"})
+
+			// every seated player and a spectator builds its view from the updates
+			views := map[model.PlayerID]*model.GameState{}
+			view := func(viewer model.PlayerID) model.GameState {
+				if viewer == "spectator" {
+					return state.ForSpectator()
+				}
+				playerState, _ := state.ForPlayer(viewer)
+				return playerState
+			}
+			for _, viewer := range []model.PlayerID{"bot-1", "spectator"} {
+				views[viewer] = &model.GameState{}
+				roundTrip(t, view(viewer), views[viewer])
+			}
+			for turn := 0; !state.Ended; turn++ {
+				var action *model.Action
+				if turn < len(actions) {
+					action = actions[turn]
+				} else {
+					playerState, _ := state.ForPlayer(state.Players[0].Id)
+					next := bot.Cautious{}.NextAction(playerState)
+					if turn == len(actions)+5 {
+						// a guess, which may not fit on the table
+						next = model.Action{Type: model.ActionPlay, Card: []int{0}}
+					}
+					next.ActivePlayer = state.Players[0].Id
+					assert.Nil(t, ValidateAndCleanAction(&next, &state))
+					action = &next
+				}
+				before := pileSizesOf(&state)
+				deck = handleAction(action, &state, deck)
+				countAction(&state)
+				spectatorUpdate := newUpdate(&state, before)
+				for viewer, previous := range views {
+					update := model.Update{}
+					if viewer == "spectator" {
+						roundTrip(t, spectatorUpdate, &update)
+					} else {
+						roundTrip(t, spectatorUpdate.ForPlayer(viewer), &update)
+					}
+					previous.Apply(update)
+					expected := model.GameState{}
+					roundTrip(t, view(viewer), &expected)
+					assert.Equal(t, expected, *previous, "%s after %s by %s", viewer, action.Type, action.ActivePlayer)
+				}
+				if action.Type == model.ActionJoin {
+					views[action.ActivePlayer] = &model.GameState{}
+					roundTrip(t, view(action.ActivePlayer), views[action.ActivePlayer])
+				}
+			}
+			assert.Equal(t, players+1, len(views))
+		})
+	}
+}
+
+func TestUpdateHistory(t *testing.T) {
+	history := updateHistory{}
+	current := int64(updateHistorySize + 11)
+	for seq := int64(2); seq <= current; seq++ {
+		history.add(model.Update{Seq: seq})
+	}
+	testCases := []struct {
+		description string
+		since       int64
+		missed      int
+		ok          bool
+	}{
+		{description: "Nothing missed", since: current, missed: 0, ok: true},
+		{description: "Missed two", since: current - 2, missed: 2, ok: true},
+		{description: "Missed the oldest kept", since: 11, missed: updateHistorySize, ok: true},
+		{description: "No longer kept", since: 10, ok: false},
+		{description: "Ahead of the game", since: current + 1, ok: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			updates, ok := history.since(tc.since, current)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.missed, len(updates))
+			for i, update := range updates {
+				assert.Equal(t, tc.since+int64(i)+1, update.Seq)
+			}
+		})
+	}
+}
+
+func TestHandleGameActions_Resync(t *testing.T) {
+	upConn := NewMockConnection()
+	downConn := NewMockConnection()
+	spectatorConn := NewMockConnection()
+	game := model.Game{
+		Id:          "game",
+		Connections: map[model.PlayerID]model.Connection{"Up": upConn, "Down": downConn},
+		Spectators:  map[model.PlayerID]model.Connection{"spectator": spectatorConn},
+		Actions:     make(chan *model.Action, 5),
+	}
+	go HandleGameActions(&game, []model.Card{w1, w2, w3, w4, w5, r1, r2, r3, r4, r5, b1, b2})
+	views := map[*MockConnection]*model.GameState{}
+	read := func(conn *MockConnection) (string, model.GameState) {
+		msg := <-conn.Messages
+		envelope, err := io.Decode(msg)
+		assert.Nil(t, err)
+		if views[conn] == nil {
+			views[conn] = &model.GameState{}
+		}
+		assert.Nil(t, applyMessage(views[conn], msg))
+		return envelope.Type, *views[conn]
+	}
+	act := func(action *model.Action) {
+		game.Actions <- action
+		for _, conn := range []*MockConnection{upConn, downConn, spectatorConn} {
+			read(conn)
+		}
+	}
+	act(&model.Action{Type: model.ActionCreate, ActivePlayer: "Up"})
+	act(&model.Action{Type: model.ActionJoin, ActivePlayer: "Down"})
+	act(&model.Action{Type: model.ActionStart, ActivePlayer: "Up"})
+	missed := *views[downConn]
+	assert.Equal(t, int64(3), missed.Seq)
+	act(&model.Action{Type: model.ActionClue, ActivePlayer: "Up", TargetPlayer: "Down", Clue: "R", Card: []int{}})
+	act(&model.Action{Type: model.ActionDiscard, ActivePlayer: "Down", Card: []int{0}})
+	current := *views[downConn]
+
+	// Down lost the last two updates, and gets them again
+	views[downConn] = &missed
+	game.Actions <- &model.Action{Type: model.ActionResync, ActivePlayer: "Down", Since: 3}
+	messageType, resynced := read(downConn)
+	assert.Equal(t, model.MessageUpdate, messageType)
+	assert.Equal(t, int64(4), resynced.Seq)
+	messageType, resynced = read(downConn)
+	assert.Equal(t, model.MessageUpdate, messageType)
+	assert.Equal(t, current, resynced)
+
+	// the create is not kept, so a client that missed it gets the whole state
+	game.Actions <- &model.Action{Type: model.ActionResync, ActivePlayer: "spectator", Since: 0}
+	messageType, resynced = read(spectatorConn)
+	assert.Equal(t, model.MessageState, messageType)
+	assert.Equal(t, *views[spectatorConn], resynced)
+	assert.Equal(t, int64(5), resynced.Seq)
+
+	game.Actions <- &model.Action{Type: model.ActionResync, ActivePlayer: "Up", Since: 5}
+	game.Actions <- &model.Action{Type: model.ActionPing, ActivePlayer: "Up"}
+	messageType, _ = read(upConn)
+	assert.Equal(t, model.MessageState, messageType, "nothing is sent when nothing was missed")
+	assert.Equal(t, 0, len(downConn.Messages))
+	assert.Equal(t, 0, len(spectatorConn.Messages))
+}
@@ -8,13 +8,57 @@ const (
 	ActionClue    = "clue"
 	ActionPlay    = "play"
 	ActionDiscard = "discard"
+	// ActionSpectate attaches a read-only connection, that sees every hand, to a game
+	ActionSpectate = "spectate"
+	// ActionAddBot seats a bot, played by the server, in a game that has not started
+	ActionAddBot = "add_bot"
+	// ActionListGames asks for the lobby, before joining a game
+	ActionListGames = "list_games"
+	// ActionSubscribeLobby asks for the lobby, and for a new lobby every time a game changes
+	ActionSubscribeLobby = "subscribe_lobby"
+	// ActionChat sends a message to everyone in the game. It is not a move, and may be sent at any time.
+	ActionChat = "chat"
+	// ActionResync asks for the updates after the one numbered Since, e.g. after a client notices a gap in the numbers
+	ActionResync = "resync"
+	// ActionLeave gives up a seat in a game that has not started, and ends a game that has started
+	ActionLeave = "leave"
+	// ActionTimeout ends a game where a player ran out of time. It is played by the server, never by a player.
+	ActionTimeout = "timeout"
+	// ActionAbandon ends a game that no one has been connected to for a while. It is played by the server, never by a player.
+	ActionAbandon = "abandon"
+)
+
+const (
+	VariantStandard = ""
+	// VariantRainbow adds a sixth suit that is touched by every color clue
+	VariantRainbow = "rainbow"
 )
 
 type Action struct {
-	Type         string   `json:"type"`
-	GameID       GameID   `json:"game,omitempty"`
-	ActivePlayer PlayerID `json:"activePlayer,omitempty"`
-	TargetPlayer PlayerID `json:"targetPlayer,omitempty"`
-	Card         []int    `json:"card,omitempty"`
-	Clue         string   `json:"clue,omitempty"`
+	Type string `json:"type"`
+	// Id is chosen by the client, and is sent back in the error if the action fails
+	Id           string       `json:"id,omitempty"`
+	GameID       GameID       `json:"game,omitempty"`
+	ActivePlayer PlayerID     `json:"activePlayer,omitempty"`
+	TargetPlayer PlayerID     `json:"targetPlayer,omitempty"`
+	Card         []int        `json:"card,omitempty"`
+	Clue         string       `json:"clue,omitempty"`
+	Token        string       `json:"token,omitempty"`
+	Options      *GameOptions `json:"options,omitempty"`
+	// Seed shuffles the deck of a created game. The server picks a random seed if it is not set.
+	// Any seed may be set, including 0.
+	Seed *int64 `json:"seed,omitempty"`
+	// Message is the text of a chat action
+	Message string `json:"message,omitempty"`
+	// Since is the number of the last update a client has seen, in a resync action
+	Since int64 `json:"since,omitempty"`
+}
+
+// IsTurn returns true for the actions that a player takes on their turn
+func (a Action) IsTurn() bool {
+	switch a.Type {
+	case ActionClue, ActionPlay, ActionDiscard:
+		return true
+	}
+	return false
 }
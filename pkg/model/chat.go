@@ -0,0 +1,12 @@
+package model
+
+// ChatMessage is a message from a player to everyone in the game
+type ChatMessage struct {
+	Player  PlayerID `json:"player"`
+	Message string   `json:"message"`
+}
+
+// Chat is sent to everyone in the game when a player chats, and with the latest messages to a player that joins
+type Chat struct {
+	Messages []ChatMessage `json:"chat"`
+}
@@ -0,0 +1,9 @@
+package model
+
+// Connection is a message based transport to a single client, e.g. a TCP socket or a WebSocket.
+// Every message is a single JSON encoded object.
+type Connection interface {
+	ReadAction() (*Action, error)
+	Write(interface{}) (int, error)
+	Close() error
+}
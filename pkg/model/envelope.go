@@ -0,0 +1,23 @@
+package model
+
+import "encoding/json"
+
+// the types of the messages the server sends
+const (
+	MessageState  = "state"
+	MessageUpdate = "update"
+	MessageError  = "error"
+	MessageEvent  = "event"
+	MessageChat   = "chat"
+	MessageLobby  = "lobby"
+)
+
+// Envelope wraps every message the server sends, so that clients can tell them apart by type
+type Envelope struct {
+	// Version is the version of the protocol, and changes when old clients can no longer read the messages
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	// Seq numbers the messages sent on a connection, starting from 1
+	Seq     int64           `json:"seq"`
+	Payload json.RawMessage `json:"payload"`
+}
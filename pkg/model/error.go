@@ -1,6 +1,60 @@
 package model
 
+import "fmt"
+
+// ErrorCode tells clients what went wrong. Codes never change, while the messages may.
+type ErrorCode string
+
+const (
+	// ErrBadRequest is a message that could not be read
+	ErrBadRequest ErrorCode = "bad_request"
+	// ErrTimeout closes a connection that sent nothing for too long
+	ErrTimeout ErrorCode = "timeout"
+	// ErrUnknownAction is an action of a type that the server does not know
+	ErrUnknownAction ErrorCode = "unknown_action"
+	// ErrInvalidAction is an action with missing or invalid fields
+	ErrInvalidAction ErrorCode = "invalid_action"
+	// ErrInvalidOptions are game options that break the rules
+	ErrInvalidOptions ErrorCode = "invalid_options"
+	ErrNotInGame      ErrorCode = "not_in_game"
+	ErrAlreadyInGame  ErrorCode = "already_in_game"
+	ErrGameNotFound   ErrorCode = "game_not_found"
+	ErrGameExists     ErrorCode = "game_exists"
+	// ErrGameFull is a game where every seat is taken, or a player with too many connections
+	ErrGameFull       ErrorCode = "game_full"
+	ErrGameStarted    ErrorCode = "game_started"
+	ErrGameNotStarted ErrorCode = "game_not_started"
+	ErrNotYourTurn    ErrorCode = "not_your_turn"
+	ErrNoClues        ErrorCode = "no_clues"
+	// ErrNotCreator is an action that only the creator of the game may take
+	ErrNotCreator    ErrorCode = "not_creator"
+	ErrTooFewPlayers ErrorCode = "too_few_players"
+	// ErrPlayerNotFound is a clue to a player that is not in the game
+	ErrPlayerNotFound ErrorCode = "player_not_found"
+	// ErrNameTaken is a bot named after a player that is already in the game
+	ErrNameTaken ErrorCode = "name_taken"
+	// ErrInvalidSession is a join as a player that is in the game, without the session token of the player
+	ErrInvalidSession ErrorCode = "invalid_session"
+	// ErrForbidden is an action the connection may not take, e.g. on behalf of another player
+	ErrForbidden    ErrorCode = "forbidden"
+	ErrRateLimited  ErrorCode = "rate_limited"
+	ErrShuttingDown ErrorCode = "shutting_down"
+	// ErrServerError is a failure of the server, rather than of the client
+	ErrServerError ErrorCode = "server_error"
+)
+
+// Error is sent to a client whose message failed
 type Error struct {
-	Err int
-	Message string
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// ActionId is the id of the action that failed, if the client gave it one
+	ActionId string `json:"id,omitempty"`
+}
+
+func NewError(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
 }
@@ -0,0 +1,14 @@
+package model
+
+const (
+	// EventShutdown is sent when the server stops. Games that have not ended when the
+	// grace period is over are restored when the server starts again.
+	EventShutdown = "shutdown"
+)
+
+// ServerEvent tells clients about the server, rather than about a game
+type ServerEvent struct {
+	Event string `json:"event"`
+	// GraceSeconds is how long the server waits for running games to end, before it closes every connection
+	GraceSeconds int `json:"graceSeconds,omitempty"`
+}
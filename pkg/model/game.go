@@ -1,10 +1,72 @@
 package model
 
-import "net"
+import (
+	"sync"
+	"time"
+)
 
 type Game struct {
-	Id          GameID
-	Connections map[PlayerID]net.Conn
-	Actions     chan *Action
-	State       *GameState
+	Id GameID
+	// Connections holds the connection of every player. It is nil for a player that has disconnected, but may rejoin.
+	Connections map[PlayerID]Connection
+	// Spectators are read-only connections, that are not players of the game
+	Spectators map[PlayerID]Connection
+	// Sessions holds the secret token each player must present to reconnect
+	Sessions map[PlayerID]string
+	Actions  chan *Action
+	// State belongs to the goroutine running the game. Other goroutines read the Snapshot.
+	State *GameState
+	// Options are set when the game is created, and never change
+	Options GameOptions
+	// Log is set when the game is created, if the server persists games
+	Log GameLog
+	// Publish is optional. The game calls it with its summary whenever the summary changes.
+	Publish func(summary GameSummary)
+	// IdleTimeout is optional. The game is abandoned when no player or spectator has been connected to it for this long.
+	IdleTimeout time.Duration
+	// Chat holds the latest chat messages of the game. It belongs to the goroutine running the game.
+	Chat []ChatMessage
+	// snapshot is a copy of State as of the last action
+	snapshot *GameState
+	// guards Connections, Spectators, Sessions and snapshot
+	sync.Mutex
+}
+
+// SetSnapshot stores a copy of the state, for other goroutines to read. It is called by the
+// goroutine running the game after every action.
+func (g *Game) SetSnapshot(state *GameState) {
+	snapshot := state.Copy()
+	g.Lock()
+	defer g.Unlock()
+	g.snapshot = &snapshot
+}
+
+// Snapshot returns the state as of the last action, or nil before the game has a state. It is
+// shared, and must not be changed.
+func (g *Game) Snapshot() *GameState {
+	g.Lock()
+	defer g.Unlock()
+	return g.snapshot
+}
+
+// CopyConnections returns a copy of Connections that can be used without holding the lock.
+func (g *Game) CopyConnections() map[PlayerID]Connection {
+	g.Lock()
+	defer g.Unlock()
+	return copyConnections(g.Connections)
+}
+
+// CopySpectators returns a copy of Spectators that can be used without holding the lock.
+func (g *Game) CopySpectators() map[PlayerID]Connection {
+	g.Lock()
+	defer g.Unlock()
+	return copyConnections(g.Spectators)
+}
+
+func copyConnections(connections map[PlayerID]Connection) map[PlayerID]Connection {
+	connectionsCopy := make(map[PlayerID]Connection, len(connections))
+	for id, conn := range connections {
+		connectionsCopy[id] = conn
+	}
+	return connectionsCopy
 }
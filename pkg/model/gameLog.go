@@ -0,0 +1,10 @@
+package model
+
+// GameLog persists everything needed to restore a game after a restart of the server.
+type GameLog interface {
+	WriteDeck([]Card) error
+	WriteSession(Session) error
+	WriteAction(*Action) error
+	// Close closes the log. The log of an ended game is discarded, since it will never be restored.
+	Close(ended bool) error
+}
@@ -0,0 +1,69 @@
+package model
+
+const (
+	DefaultMaxLives   = 3
+	DefaultMaxClues   = 8
+	DefaultMinPlayers = 2
+	DefaultMaxPlayers = 5
+)
+
+// what happens when a player of a timed game runs out of time
+const (
+	// TimeoutDiscard discards the oldest card in the hand of the player that has not been clued
+	TimeoutDiscard = "discard"
+	// TimeoutEnd ends the game
+	TimeoutEnd = "end"
+)
+
+// GameOptions are the house rules of a game, chosen by the creator. Unset fields use the default rules.
+type GameOptions struct {
+	Variant string `json:"variant,omitempty"`
+	// HandSize is the number of cards dealt to each player. Unset means 5 cards for 2-3 players and 4 for more.
+	HandSize   int `json:"handSize,omitempty"`
+	MaxLives   int `json:"maxLives,omitempty"`
+	MaxClues   int `json:"maxClues,omitempty"`
+	MinPlayers int `json:"minPlayers,omitempty"`
+	MaxPlayers int `json:"maxPlayers,omitempty"`
+	// TurnSeconds is the time a player has for each turn. Games without turn seconds or bank seconds are not timed.
+	TurnSeconds int `json:"turnSeconds,omitempty"`
+	// BankSeconds is the time each player may spend over the whole game, after the time of a turn has run out
+	BankSeconds int `json:"bankSeconds,omitempty"`
+	// OnTimeout is TimeoutDiscard or TimeoutEnd. Unset means TimeoutDiscard.
+	OnTimeout string `json:"onTimeout,omitempty"`
+	// FullFinalRound lets the game go on after the last card is drawn until the end of the round, rather than
+	// for one turn for each player, so that every player has had as many turns when the game ends
+	FullFinalRound bool `json:"fullFinalRound,omitempty"`
+}
+
+// WithDefaults returns a copy of the options where every unset field, except HandSize, has its default value.
+func (o GameOptions) WithDefaults() GameOptions {
+	if o.MaxLives == 0 {
+		o.MaxLives = DefaultMaxLives
+	}
+	if o.MaxClues == 0 {
+		o.MaxClues = DefaultMaxClues
+	}
+	if o.MinPlayers == 0 {
+		o.MinPlayers = DefaultMinPlayers
+	}
+	if o.MaxPlayers == 0 {
+		o.MaxPlayers = DefaultMaxPlayers
+	}
+	return o
+}
+
+// CardsPerPlayer returns the hand size for a game with the given number of players.
+func (o GameOptions) CardsPerPlayer(players int) int {
+	if o.HandSize > 0 {
+		return o.HandSize
+	}
+	if players > 3 {
+		return 4
+	}
+	return 5
+}
+
+// IsTimed returns true if the players of the game have a limited time for their turns
+func (o GameOptions) IsTimed() bool {
+	return o.TurnSeconds > 0 || o.BankSeconds > 0
+}
@@ -0,0 +1,14 @@
+package model
+
+// GameRecord is the history of a game, with everything needed to replay it from the start.
+type GameRecord struct {
+	Id      GameID
+	Options GameOptions
+	Seed    int64
+	// Players in seat order. The first player takes the first turn.
+	Players []PlayerID
+	// Deck is the shuffled deck, before any cards are dealt
+	Deck []Card
+	// Actions in the order they were handled, including the cards touched by clues
+	Actions []Action
+}
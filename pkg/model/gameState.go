@@ -1,30 +1,123 @@
 package model
 
-import (
-	"math/rand"
-	"time"
-)
+import "math/rand"
 
 type GameID string
 type PlayerID string
 
 type GameState struct {
-	Id           GameID   `json:"id,omitempty"`
-	Players      []Player `json:"players"`
-	Clues        int      `json:"clues"`
-	Lives        int      `json:"lives"`
-	Discards     []Card   `json:"discards"`
-	Table        []Card   `json:"table"`
-	Deck         int      `json:"deck"`
-	PlayedAction Action   `json:"playedAction"`
-	Started      bool     `json:"started"`
-	Ended        bool     `json:"ended"`
-	Colors       int      `json:"colors"`
+	Id       GameID   `json:"id,omitempty"`
+	Players  []Player `json:"players"`
+	Clues    int      `json:"clues"`
+	Lives    int      `json:"lives"`
+	Discards []Card   `json:"discards"`
+	Table    []Card   `json:"table"`
+	Deck     int      `json:"deck"`
+	// Turn counts the turns played since the game started
+	Turn int `json:"turn"`
+	// TurnsRemaining is the number of turns left once the last card has been drawn, and zero before
+	TurnsRemaining int `json:"turnsRemaining,omitempty"`
+	// Seq numbers the actions played in the game. Pings are not counted.
+	Seq          int64       `json:"seq"`
+	PlayedAction Action      `json:"playedAction"`
+	Started      bool        `json:"started"`
+	Ended        bool        `json:"ended"`
+	Colors       int         `json:"colors"`
+	Options      GameOptions `json:"options"`
+	Session      *Session    `json:"session,omitempty"`
+	// Seed reveals the order of the deck, and is only sent when the game has ended
+	Seed *int64 `json:"seed,omitempty"`
+	// Clock is the time left when the state was sent, in games that are timed
+	Clock *Clock `json:"clock,omitempty"`
+}
+
+// Clock counts down from when the state was sent. Times are in milliseconds.
+type Clock struct {
+	// Turn is the time left of the current turn, before the player starts using their time bank
+	Turn int64 `json:"turn"`
+	// Banks is the time left in the time bank of every player
+	Banks map[PlayerID]int64 `json:"banks"`
+}
+
+// Session is sent only to the connection that created or joined a game.
+// The token must be sent with a later join to reconnect as the same player.
+type Session struct {
+	Player PlayerID `json:"player"`
+	Token  string   `json:"token"`
 }
 
 type Player struct {
 	Id    PlayerID `json:"id"`
 	Cards []Card   `json:"cards,omitempty"`
+	// Knowledge is what the player has been told about the card with the same index in Cards.
+	// It is sent to the player, even though the cards are not.
+	Knowledge []CardKnowledge `json:"knowledge,omitempty"`
+}
+
+// RemoveCard takes the card with the index out of the hand, with what the player knows about it
+func (p *Player) RemoveCard(index int) {
+	if index < len(p.Cards) {
+		p.Cards = append(p.Cards[:index:index], p.Cards[index+1:]...)
+	}
+	if index < len(p.Knowledge) {
+		p.Knowledge = append(p.Knowledge[:index:index], p.Knowledge[index+1:]...)
+	}
+}
+
+// CardKnowledge holds the colors and values a card has been positively and negatively clued with
+type CardKnowledge struct {
+	Colors    []string `json:"colors,omitempty"`
+	Values    []string `json:"values,omitempty"`
+	NotColors []string `json:"notColors,omitempty"`
+	NotValues []string `json:"notValues,omitempty"`
+}
+
+// AddClue records a clue given to the hand holding the card, and whether it touched the card
+func (k *CardKnowledge) AddClue(clue string, touched bool) {
+	switch {
+	case isClueColor(clue) && touched:
+		k.Colors = appendMissing(k.Colors, clue)
+	case isClueColor(clue):
+		k.NotColors = appendMissing(k.NotColors, clue)
+	case touched:
+		k.Values = appendMissing(k.Values, clue)
+	default:
+		k.NotValues = appendMissing(k.NotValues, clue)
+	}
+}
+
+// Allows returns false if the clues rule out that the card is the given card
+func (k CardKnowledge) Allows(card Card) bool {
+	for _, color := range k.Colors {
+		if !card.IsTouchedBy(color) {
+			return false
+		}
+	}
+	for _, color := range k.NotColors {
+		if card.IsTouchedBy(color) {
+			return false
+		}
+	}
+	for _, value := range k.Values {
+		if card.Value != value {
+			return false
+		}
+	}
+	for _, value := range k.NotValues {
+		if card.Value == value {
+			return false
+		}
+	}
+	return true
+}
+
+func appendMissing(clues []string, clue string) []string {
+	for _, c := range clues {
+		if c == clue {
+			return clues
+		}
+	}
+	return append(clues, clue)
 }
 
 type Card struct {
@@ -32,31 +125,106 @@ type Card struct {
 	Value string `json:"value"`
 }
 
+// ColorRainbow is the color of the rainbow suit. It can not be named in a clue.
+const ColorRainbow = "M"
+
+// IsTouchedBy returns true if a color or value clue touches the card.
+// Rainbow cards are touched by every color clue.
+func (c Card) IsTouchedBy(clue string) bool {
+	if c.Color == clue || c.Value == clue {
+		return true
+	}
+	return c.Color == ColorRainbow && isClueColor(clue)
+}
+
+func isClueColor(clue string) bool {
+	switch clue {
+	case "B", "G", "R", "W", "Y":
+		return true
+	}
+	return false
+}
+
 func (g *GameState) ForPlayer(playerID PlayerID) (GameState, bool) {
 	filtered := make([]Player, len(g.Players))
 	ok := false
 	for i, player := range g.Players {
 		if player.Id == playerID {
-			filtered[i] = Player{Id: playerID}
+			filtered[i] = Player{Id: playerID, Knowledge: player.Knowledge}
 			ok = true
 		} else {
 			filtered[i] = player
 		}
 	}
 	return GameState{
-		Id:           g.Id,
-		Players:      filtered,
-		Clues:        g.Clues,
-		Lives:        g.Lives,
-		Discards:     g.Discards,
-		Table:        g.Table,
-		Deck:         g.Deck,
-		PlayedAction: g.PlayedAction,
-		Started:      g.Started,
-		Ended:        g.Ended,
+		Id:             g.Id,
+		Players:        filtered,
+		Clues:          g.Clues,
+		Lives:          g.Lives,
+		Discards:       g.Discards,
+		Table:          g.Table,
+		Deck:           g.Deck,
+		Turn:           g.Turn,
+		TurnsRemaining: g.TurnsRemaining,
+		Seq:            g.Seq,
+		PlayedAction:   g.PlayedAction,
+		Started:        g.Started,
+		Ended:          g.Ended,
+		Colors:         g.Colors,
+		Options:        g.Options,
+		Seed:           g.revealedSeed(),
+		Clock:          g.Clock,
 	}, ok
 }
 
+// ForSpectator returns the state with every hand visible.
+func (g *GameState) ForSpectator() GameState {
+	return GameState{
+		Id:             g.Id,
+		Players:        g.Players,
+		Clues:          g.Clues,
+		Lives:          g.Lives,
+		Discards:       g.Discards,
+		Table:          g.Table,
+		Deck:           g.Deck,
+		Turn:           g.Turn,
+		TurnsRemaining: g.TurnsRemaining,
+		Seq:            g.Seq,
+		PlayedAction:   g.PlayedAction,
+		Started:        g.Started,
+		Ended:          g.Ended,
+		Colors:         g.Colors,
+		Options:        g.Options,
+		Seed:           g.revealedSeed(),
+		Clock:          g.Clock,
+	}
+}
+
+func (g *GameState) revealedSeed() *int64 {
+	if g.Ended {
+		return g.Seed
+	}
+	return nil
+}
+
+// IsPlayable returns true if the card can be played on the table without losing a life
+func (g *GameState) IsPlayable(card Card) bool {
+	requiredCardPlayed := card.Value == "1"
+	for _, c := range g.Table {
+		if c == card {
+			return false
+		}
+		if c.Color == card.Color &&
+			(c.Value == "1" && card.Value == "2" ||
+				c.Value == "2" && card.Value == "3" ||
+				c.Value == "3" && card.Value == "4" ||
+				c.Value == "4" && card.Value == "5") {
+			requiredCardPlayed = true
+		}
+	}
+	return requiredCardPlayed
+}
+
 func (g *GameState) HasPlayer(player PlayerID) bool {
 	for _, p := range g.Players {
 		if p.Id == player {
@@ -66,8 +234,45 @@ func (g *GameState) HasPlayer(player PlayerID) bool {
 	return false
 }
 
-func CreateDeck() []Card {
+// Copy returns a state that shares no hands, piles or clues with the state. The clock is
+// shared, since it is replaced rather than changed.
+func (g *GameState) Copy() GameState {
+	copied := *g
+	copied.Players = nil
+	for _, player := range g.Players {
+		var knowledge []CardKnowledge
+		for _, k := range player.Knowledge {
+			knowledge = append(knowledge, CardKnowledge{
+				Colors:    append([]string(nil), k.Colors...),
+				Values:    append([]string(nil), k.Values...),
+				NotColors: append([]string(nil), k.NotColors...),
+				NotValues: append([]string(nil), k.NotValues...),
+			})
+		}
+		copied.Players = append(copied.Players, Player{
+			Id:        player.Id,
+			Cards:     append([]Card(nil), player.Cards...),
+			Knowledge: knowledge,
+		})
+	}
+	copied.Discards = append([]Card(nil), g.Discards...)
+	copied.Table = append([]Card(nil), g.Table...)
+	copied.PlayedAction.Card = append([]int(nil), g.PlayedAction.Card...)
+	return copied
+}
+
+// VariantColors returns the colors of the suits in a variant
+func VariantColors(variant string) []string {
 	colors := []string{"B", "G", "R", "W", "Y"}
+	if variant == VariantRainbow {
+		colors = append(colors, ColorRainbow)
+	}
+	return colors
+}
+
+// CreateDeck returns a shuffled deck. The same seed always gives the same deck.
+func CreateDeck(variant string, seed int64) []Card {
+	colors := VariantColors(variant)
 	values := []string{"1", "1", "1", "2", "2", "3", "3", "4", "4", "5"}
 	deck := make([]Card, len(colors)*len(values))
 	i := 0
@@ -80,7 +285,7 @@ func CreateDeck() []Card {
 			i++
 		}
 	}
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+	random := rand.New(rand.NewSource(seed))
+	random.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
 	return deck
 }
@@ -73,6 +73,8 @@ func TestGameState_ForPlayer(t *testing.T) {
 		},
 		Deck:         30,
 		PlayedAction: Action{},
+		Colors:       6,
+		Options:      GameOptions{Variant: VariantRainbow},
 	}
 
 	testCases := []struct {
@@ -114,6 +116,8 @@ func TestGameState_ForPlayer(t *testing.T) {
 				assert.Equal(t, tc.GameState.Lives, newState.Lives)
 				assert.Equal(t, tc.GameState.Clues, newState.Clues)
 				assert.Equal(t, tc.GameState.Id, newState.Id)
+				assert.Equal(t, tc.GameState.Colors, newState.Colors)
+				assert.Equal(t, tc.GameState.Options, newState.Options)
 				for _, player := range newState.Players {
 					if player.Id == tc.playerID {
 						assert.Nil(t, player.Cards)
@@ -129,7 +133,7 @@ func TestGameState_ForPlayer(t *testing.T) {
 }
 
 func TestGameState_CreateDeck(t *testing.T) {
-	deck := CreateDeck()
+	deck := CreateDeck(VariantStandard, 1)
 	assert.Equal(t, 50, len(deck))
 	cards := map[Card]int{}
 	for _, card := range deck {
@@ -162,3 +166,131 @@ func TestGameState_CreateDeck(t *testing.T) {
 		assert.Equal(t, 10, count, color, "should have 10 cards")
 	}
 }
+
+func TestGameState_ForSpectator(t *testing.T) {
+	seed := int64(42)
+	state := GameState{
+		Id: "id",
+		Players: []Player{
+			{Id: "p1", Cards: []Card{{Color: "W", Value: "1"}}},
+			{Id: "p2", Cards: []Card{{Color: "B", Value: "1"}}},
+		},
+		Clues:   3,
+		Lives:   2,
+		Started: true,
+		Colors:  5,
+		Seed:    &seed,
+	}
+	spectatorState := state.ForSpectator()
+	assert.Equal(t, state.Players, spectatorState.Players, "spectators see every hand")
+	assert.Nil(t, spectatorState.Seed, "the seed must not be revealed before the game ends")
+	spectatorState.Seed = state.Seed
+	assert.Equal(t, state, spectatorState)
+}
+
+func TestGameState_ForPlayer_Seed(t *testing.T) {
+	seed := int64(0)
+	state := GameState{Players: []Player{{Id: "p1"}}, Started: true, Seed: &seed}
+	newState, _ := state.ForPlayer("p1")
+	assert.Nil(t, newState.Seed, "the seed must not be revealed before the game ends")
+
+	state.Ended = true
+	newState, _ = state.ForPlayer("p1")
+	if assert.NotNil(t, newState.Seed, "0 is revealed like any other seed") {
+		assert.Equal(t, int64(0), *newState.Seed)
+	}
+}
+
+func TestGameState_CreateDeck_Seed(t *testing.T) {
+	assert.Equal(t, CreateDeck(VariantStandard, 42), CreateDeck(VariantStandard, 42))
+	assert.NotEqual(t, CreateDeck(VariantStandard, 42), CreateDeck(VariantStandard, 43))
+}
+
+func TestGameState_CreateDeck_Rainbow(t *testing.T) {
+	deck := CreateDeck(VariantRainbow, 1)
+	assert.Equal(t, 60, len(deck))
+	rainbow := map[string]int{}
+	for _, card := range deck {
+		if card.Color == ColorRainbow {
+			rainbow[card.Value]++
+		}
+	}
+	assert.Equal(t, map[string]int{"1": 3, "2": 2, "3": 2, "4": 2, "5": 1}, rainbow)
+}
+
+func TestCard_IsTouchedBy(t *testing.T) {
+	testCases := []struct {
+		description string
+		card        Card
+		clue        string
+		expected    bool
+	}{
+		{description: "color match", card: Card{Color: "B", Value: "1"}, clue: "B", expected: true},
+		{description: "value match", card: Card{Color: "B", Value: "1"}, clue: "1", expected: true},
+		{description: "no match", card: Card{Color: "B", Value: "1"}, clue: "R", expected: false},
+		{description: "rainbow color", card: Card{Color: ColorRainbow, Value: "1"}, clue: "R", expected: true},
+		{description: "rainbow value", card: Card{Color: ColorRainbow, Value: "1"}, clue: "1", expected: true},
+		{description: "rainbow other value", card: Card{Color: ColorRainbow, Value: "1"}, clue: "2", expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.card.IsTouchedBy(tc.clue))
+		})
+	}
+}
+
+func TestGameState_ForPlayer_Knowledge(t *testing.T) {
+	knowledge := []CardKnowledge{{Values: []string{"1"}}, {NotValues: []string{"1"}}}
+	state := GameState{Players: []Player{
+		{Id: "p1", Cards: []Card{{Color: "W", Value: "1"}, {Color: "W", Value: "2"}}, Knowledge: knowledge},
+		{Id: "p2", Cards: []Card{{Color: "B", Value: "1"}}, Knowledge: []CardKnowledge{{}}},
+	}}
+	newState, _ := state.ForPlayer("p1")
+	assert.Equal(t, Player{Id: "p1", Knowledge: knowledge}, newState.Players[0], "players know what they have been told about their cards")
+	assert.Equal(t, state.Players[1], newState.Players[1])
+}
+
+func TestCardKnowledge_AddClue(t *testing.T) {
+	// a rainbow card touched by two different color clues
+	knowledge := CardKnowledge{}
+	knowledge.AddClue("R", true)
+	knowledge.AddClue("B", true)
+	knowledge.AddClue("R", true)
+	knowledge.AddClue("1", false)
+	knowledge.AddClue("W", false)
+	knowledge.AddClue("2", true)
+	assert.Equal(t, CardKnowledge{
+		Colors:    []string{"R", "B"},
+		Values:    []string{"2"},
+		NotColors: []string{"W"},
+		NotValues: []string{"1"},
+	}, knowledge)
+}
+
+func TestCardKnowledge_Allows(t *testing.T) {
+	// told it is red, and not a 1
+	knowledge := CardKnowledge{Colors: []string{"R"}, NotValues: []string{"1"}}
+	assert.True(t, knowledge.Allows(Card{Color: "R", Value: "2"}))
+	assert.True(t, knowledge.Allows(Card{Color: ColorRainbow, Value: "3"}))
+	assert.False(t, knowledge.Allows(Card{Color: "R", Value: "1"}))
+	assert.False(t, knowledge.Allows(Card{Color: "B", Value: "2"}))
+	// told it is not blue
+	knowledge = CardKnowledge{NotColors: []string{"B"}}
+	assert.False(t, knowledge.Allows(Card{Color: ColorRainbow, Value: "3"}))
+}
+
+func TestGameState_Copy(t *testing.T) {
+	state := GameState{
+		Players: []Player{{Id: "p1", Cards: []Card{{Color: "R", Value: "1"}}, Knowledge: []CardKnowledge{{Colors: []string{"R"}}}}},
+		Table:   []Card{{Color: "B", Value: "1"}},
+	}
+	copied := state.Copy()
+	assert.Equal(t, state, copied)
+
+	state.Players[0].Cards[0] = Card{Color: "G", Value: "2"}
+	state.Players[0].Knowledge[0].AddClue("1", true)
+	state.Table[0] = Card{Color: "W", Value: "1"}
+	assert.Equal(t, Card{Color: "R", Value: "1"}, copied.Players[0].Cards[0])
+	assert.Equal(t, CardKnowledge{Colors: []string{"R"}}, copied.Players[0].Knowledge[0])
+	assert.Equal(t, Card{Color: "B", Value: "1"}, copied.Table[0])
+}
@@ -0,0 +1,27 @@
+package model
+
+// GameSummary is what the lobby shows of a game
+type GameSummary struct {
+	Id         GameID `json:"id"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"maxPlayers"`
+	Started    bool   `json:"started"`
+	Ended      bool   `json:"ended"`
+	Variant    string `json:"variant,omitempty"`
+}
+
+// Lobby is the list of games sent to clients that are not in a game
+type Lobby struct {
+	Games []GameSummary `json:"games"`
+}
+
+func (g *GameState) Summary() GameSummary {
+	return GameSummary{
+		Id:         g.Id,
+		Players:    len(g.Players),
+		MaxPlayers: g.Options.WithDefaults().MaxPlayers,
+		Started:    g.Started,
+		Ended:      g.Ended,
+		Variant:    g.Options.Variant,
+	}
+}
@@ -0,0 +1,114 @@
+package model
+
+// Update is what one action changed in a game, as a player or spectator sees it. It is sent
+// instead of the whole state, which is only sent when a player joins, reconnects or pings.
+type Update struct {
+	// Seq is the number of the action in the game. A client that sees a gap in the numbers should resync.
+	Seq    int64  `json:"seq"`
+	Action Action `json:"action"`
+	// Players is set when the seats or every hand changed, i.e. when a player joins or leaves or the game starts
+	Players []Player `json:"players,omitempty"`
+	// Table is the card a play added to the table
+	Table *Card `json:"table,omitempty"`
+	// Discarded is the card that was discarded, or played without fitting on the table
+	Discarded *Card `json:"discarded,omitempty"`
+	// Drawn is the card that replaced a played or discarded card. It is not sent to the player that drew it.
+	// Once the deck is empty nothing is drawn, and the hand shrinks instead.
+	Drawn          *Card  `json:"drawn,omitempty"`
+	Clues          int    `json:"clues"`
+	Lives          int    `json:"lives"`
+	Deck           int    `json:"deck"`
+	Turn           int    `json:"turn"`
+	TurnsRemaining int    `json:"turnsRemaining,omitempty"`
+	Started        bool   `json:"started"`
+	Ended          bool   `json:"ended"`
+	Seed           *int64 `json:"seed,omitempty"`
+	Clock          *Clock `json:"clock,omitempty"`
+}
+
+// ForPlayer returns the update without the cards of the player, like GameState.ForPlayer does
+func (u Update) ForPlayer(playerID PlayerID) Update {
+	if u.Players != nil {
+		filtered := make([]Player, len(u.Players))
+		for i, player := range u.Players {
+			if player.Id == playerID {
+				player = Player{Id: playerID, Knowledge: player.Knowledge}
+			}
+			filtered[i] = player
+		}
+		u.Players = filtered
+	}
+	if u.Action.ActivePlayer == playerID {
+		u.Drawn = nil
+	}
+	return u
+}
+
+// Apply changes the state the way the update says. The state must be the state of the same player
+// or spectator as the update, as it was before the action. Applying every update sent to a player
+// to the last full state gives the same state the server would have sent.
+func (g *GameState) Apply(u Update) {
+	action := u.Action
+	if u.Players != nil {
+		g.Players = u.Players
+	}
+	switch action.Type {
+	case ActionClue:
+		for p := range g.Players {
+			if g.Players[p].Id == action.TargetPlayer {
+				addClue(&g.Players[p], action)
+				break
+			}
+		}
+	case ActionPlay, ActionDiscard:
+		if u.Table != nil {
+			g.Table = append(g.Table, *u.Table)
+		}
+		if u.Discarded != nil {
+			g.Discards = append(g.Discards, *u.Discarded)
+		}
+		player := &g.Players[0]
+		index := action.Card[0]
+		if g.Deck == 0 {
+			// the deck was empty before the action, so nothing was drawn
+			player.RemoveCard(index)
+		} else {
+			if u.Drawn != nil && index < len(player.Cards) {
+				player.Cards[index] = *u.Drawn
+			}
+			if index < len(player.Knowledge) {
+				player.Knowledge[index] = CardKnowledge{}
+			}
+		}
+	}
+	if action.IsTurn() {
+		g.Players = append(g.Players[1:], g.Players[0])
+	}
+	g.Seq = u.Seq
+	g.PlayedAction = action
+	g.Clues = u.Clues
+	g.Lives = u.Lives
+	g.Deck = u.Deck
+	g.Turn = u.Turn
+	g.TurnsRemaining = u.TurnsRemaining
+	g.Started = u.Started
+	g.Ended = u.Ended
+	g.Seed = u.Seed
+	g.Clock = u.Clock
+}
+
+// addClue adds a clue to the knowledge of every card in the hand. The action holds the touched cards.
+func addClue(player *Player, action Action) {
+	if len(player.Knowledge) < len(player.Cards) {
+		knowledge := make([]CardKnowledge, len(player.Cards))
+		copy(knowledge, player.Knowledge)
+		player.Knowledge = knowledge
+	}
+	for i := range player.Knowledge {
+		touched := false
+		for _, index := range action.Card {
+			touched = touched || index == i
+		}
+		player.Knowledge[i].AddClue(action.Clue, touched)
+	}
+}
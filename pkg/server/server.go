@@ -0,0 +1,173 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/egoon/hanabi-server/pkg/io"
+	"github.com/egoon/hanabi-server/pkg/logic"
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+// how often Shutdown checks whether the running games have ended
+const drainInterval = 50 * time.Millisecond
+
+// Server accepts clients over stream connections and websockets, and lets running games
+// end before it stops.
+type Server struct {
+	games *logic.GameRegistry
+	// gracePeriod is how long Shutdown waits for running games to end
+	gracePeriod time.Duration
+
+	mu          sync.Mutex
+	listeners   []net.Listener
+	httpServers []*http.Server
+	conns       map[model.Connection]bool
+	// handlers counts the connections that are still being handled
+	handlers sync.WaitGroup
+	shutdown bool
+}
+
+func New(games *logic.GameRegistry, gracePeriod time.Duration) *Server {
+	return &Server{
+		games:       games,
+		gracePeriod: gracePeriod,
+		conns:       map[model.Connection]bool{},
+	}
+}
+
+// Serve accepts stream connections, that send newline delimited JSON, until the server shuts down
+func (s *Server) Serve(ln net.Listener) error {
+	if !s.addListener(ln) {
+		_ = ln.Close()
+		return nil
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isShutdown() {
+				return nil
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				log.Warn("waiting for connection failed: ", err)
+				continue
+			}
+			return err
+		}
+		go s.handle(io.NewConnection(conn))
+	}
+}
+
+// ServeWebSocket accepts websockets, that send one JSON message per text frame, until the server shuts down
+func (s *Server) ServeWebSocket(ln net.Listener) error {
+	httpServer := &http.Server{Handler: io.NewWebSocketHandler(s.handle)}
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return nil
+	}
+	s.httpServers = append(s.httpServers, httpServer)
+	s.mu.Unlock()
+	err := httpServer.Serve(ln)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting clients, and tells every connected client that the server is going down.
+// It waits for the running games to end for at most the grace period, and then closes every connection.
+// Games that have not ended are kept in the store of the registry, if it has one.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	s.shutdown = true
+	listeners := s.listeners
+	httpServers := s.httpServers
+	conns := make([]model.Connection, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+
+	for _, ln := range listeners {
+		_ = ln.Close()
+	}
+	for _, httpServer := range httpServers {
+		// websockets are hijacked, and are not closed with the http server
+		_ = httpServer.Close()
+	}
+	s.games.Close()
+	event := model.ServerEvent{Event: model.EventShutdown, GraceSeconds: int(s.gracePeriod.Seconds())}
+	for _, conn := range conns {
+		_, _ = conn.Write(event)
+	}
+	log.Info("Shutting down. Waiting for ", s.games.Len(), " games to end")
+
+	deadline := time.Now().Add(s.gracePeriod)
+	for s.games.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainInterval)
+	}
+	for _, game := range s.games.Snapshot() {
+		// the log is closed before the connections, so that nothing that happens later is persisted
+		if game.Log != nil {
+			_ = game.Log.Close(false)
+			log.Info("Game ", game.Id, " is kept, and will be restored at the next start")
+		}
+		for _, conn := range game.CopyConnections() {
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}
+		for _, conn := range game.CopySpectators() {
+			_ = conn.Close()
+		}
+	}
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.handlers.Wait()
+}
+
+func (s *Server) handle(conn model.Connection) {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		_, _ = conn.Write(model.ServerEvent{Event: model.EventShutdown})
+		_ = conn.Close()
+		return
+	}
+	s.conns[conn] = true
+	s.handlers.Add(1)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.handlers.Done()
+	}()
+	logic.HandleConnection(conn, s.games)
+}
+
+func (s *Server) addListener(ln net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return false
+	}
+	s.listeners = append(s.listeners, ln)
+	return true
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
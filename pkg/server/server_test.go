@@ -0,0 +1,274 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/io"
+	"github.com/egoon/hanabi-server/pkg/logic"
+	"github.com/egoon/hanabi-server/pkg/model"
+	"github.com/egoon/hanabi-server/pkg/store"
+)
+
+type testClient struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	// view is the state of the game, as the last state and the updates after it tell
+	view model.GameState
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	return &testClient{conn: conn, scanner: bufio.NewScanner(conn)}
+}
+
+func (c *testClient) send(t *testing.T, action model.Action) {
+	msg, err := json.Marshal(action)
+	assert.Nil(t, err)
+	_, err = c.conn.Write(append(msg, '\n'))
+	assert.Nil(t, err)
+}
+
+func (c *testClient) read(t *testing.T, messageType string, v interface{}) {
+	assert.True(t, c.scanner.Scan(), "expected a message")
+	envelope, err := io.Decode(c.scanner.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, messageType, envelope.Type)
+	assert.Nil(t, json.Unmarshal(envelope.Payload, v))
+}
+
+// readState reads a state or an update, and returns the view of the game after it
+func (c *testClient) readState(t *testing.T) model.GameState {
+	assert.True(t, c.scanner.Scan(), "expected a message")
+	envelope, err := io.Decode(c.scanner.Bytes())
+	assert.Nil(t, err)
+	switch envelope.Type {
+	case model.MessageState:
+		c.view = model.GameState{}
+		assert.Nil(t, json.Unmarshal(envelope.Payload, &c.view))
+	case model.MessageUpdate:
+		update := model.Update{}
+		assert.Nil(t, json.Unmarshal(envelope.Payload, &update))
+		c.view.Apply(update)
+	default:
+		t.Errorf("expected a state or an update, got %s", envelope.Type)
+	}
+	return c.view
+}
+
+func (c *testClient) readEvent(t *testing.T) model.ServerEvent {
+	event := model.ServerEvent{}
+	c.read(t, model.MessageEvent, &event)
+	return event
+}
+
+// startGame lets two clients start a game where a single mistake ends the game
+func startGame(t *testing.T, addr string) (*testClient, *testClient) {
+	up := dial(t, addr)
+	seed := int64(1)
+	up.send(t, model.Action{Type: model.ActionCreate, GameID: "game", ActivePlayer: "Up", Seed: &seed, Options: &model.GameOptions{MaxLives: 1}})
+	assert.NotNil(t, up.readState(t).Session)
+	up.readState(t)
+	down := dial(t, addr)
+	down.send(t, model.Action{Type: model.ActionJoin, GameID: "game", ActivePlayer: "Down"})
+	assert.NotNil(t, down.readState(t).Session)
+	down.readState(t)
+	up.readState(t)
+	up.send(t, model.Action{Type: model.ActionStart})
+	assert.True(t, up.readState(t).Started)
+	assert.True(t, down.readState(t).Started)
+	return up, down
+}
+
+func newServer(t *testing.T, gracePeriod time.Duration) (*Server, string, *store.FileStore) {
+	dir, err := ioutil.TempDir("", "hanabi-server")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	gameStore, err := store.NewFileStore(dir)
+	assert.Nil(t, err)
+	games := logic.NewGameRegistry()
+	games.Store = gameStore
+
+	srv := New(games, gracePeriod)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		assert.Nil(t, srv.Serve(ln))
+	}()
+	return srv, ln.Addr().String(), gameStore
+}
+
+func TestServer_Shutdown_GameEnds(t *testing.T) {
+	srv, addr, gameStore := newServer(t, time.Minute)
+	up, down := startGame(t, addr)
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.Shutdown()
+		close(stopped)
+	}()
+	shutdown := model.ServerEvent{Event: model.EventShutdown, GraceSeconds: 60}
+	assert.Equal(t, shutdown, up.readEvent(t))
+	assert.Equal(t, shutdown, down.readEvent(t))
+	_, err := net.Dial("tcp", addr)
+	assert.NotNil(t, err, "no new clients are accepted")
+
+	// the game goes on, and ends when Down plays a card that can't be played
+	up.send(t, model.Action{Type: model.ActionClue, TargetPlayer: "Down", Clue: "R"})
+	clued := up.readState(t)
+	down.readState(t)
+	unplayable := -1
+	for i, card := range clued.Players[0].Cards {
+		if card.Value != "1" {
+			unplayable = i
+		}
+	}
+	assert.NotEqual(t, -1, unplayable)
+	down.send(t, model.Action{Type: model.ActionPlay, Card: []int{unplayable}})
+	assert.True(t, up.readState(t).Ended)
+	assert.True(t, down.readState(t).Ended)
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the server did not stop when the last game ended")
+	}
+	saved, err := gameStore.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(saved), "ended games are not kept")
+}
+
+func TestServer_Shutdown_KeepsGame(t *testing.T) {
+	srv, addr, gameStore := newServer(t, 100*time.Millisecond)
+	up, down := startGame(t, addr)
+
+	wsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		assert.Nil(t, srv.ServeWebSocket(wsLn))
+	}()
+	lobby, _, err := websocket.DefaultDialer.Dial("ws://"+wsLn.Addr().String(), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, lobby.WriteJSON(model.Action{Type: model.ActionSubscribeLobby}))
+	readLobby := func(v interface{}) error {
+		_, msg, err := lobby.ReadMessage()
+		if err != nil {
+			return err
+		}
+		envelope, err := io.Decode(msg)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(envelope.Payload, v)
+	}
+	assert.Nil(t, readLobby(&model.Lobby{}))
+
+	srv.Shutdown()
+	shutdown := model.ServerEvent{Event: model.EventShutdown}
+	for _, client := range []*testClient{up, down} {
+		assert.Equal(t, shutdown, client.readEvent(t))
+		assert.False(t, client.scanner.Scan(), "the connection is closed after the grace period")
+	}
+	event := model.ServerEvent{}
+	assert.Nil(t, readLobby(&event))
+	assert.Equal(t, shutdown, event)
+	_, _, err = lobby.ReadMessage()
+	assert.NotNil(t, err)
+
+	saved, err := gameStore.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(saved), "the unfinished game is kept")
+	assert.Nil(t, logic.RestoreGame(saved[0], logic.NewGameRegistry()))
+}
+
+// TestServer_ConcurrentTurns lets both players discard as fast as they can, whether it is their turn
+// or not, until the game ends. Run with -race, it checks that the connections share no state with the game.
+func TestServer_ConcurrentTurns(t *testing.T) {
+	_, addr, gameStore := newServer(t, time.Minute)
+	up, down := startGame(t, addr)
+	discard, err := json.Marshal(model.Action{Type: model.ActionDiscard, Card: []int{0}})
+	assert.Nil(t, err)
+	discard = append(discard, '\n')
+	done := make(chan struct{}, 2)
+	for _, client := range []*testClient{up, down} {
+		go func(client *testClient) {
+			_ = client.conn.SetDeadline(time.Now().Add(10 * time.Second))
+			// every discard that is not ignored is answered, with an update or an error. The server
+			// closes the connection when the game ends.
+			for {
+				if _, err := client.conn.Write(discard); err != nil || !client.scanner.Scan() {
+					break
+				}
+			}
+			done <- struct{}{}
+		}(client)
+	}
+	<-done
+	<-done
+	for i := 0; i < 100; i++ {
+		saved, err := gameStore.Load()
+		assert.Nil(t, err)
+		if len(saved) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the game did not end")
+}
+
+func TestServer_LongMessages(t *testing.T) {
+	_, addr, _ := newServer(t, time.Minute)
+	up, down := startGame(t, addr)
+
+	// the longest chat message, in characters that take two bytes each
+	message := strings.Repeat("å", 200)
+	up.send(t, model.Action{Type: model.ActionChat, Message: message})
+	for _, client := range []*testClient{up, down} {
+		chat := model.Chat{}
+		client.read(t, model.MessageChat, &chat)
+		assert.Equal(t, []model.ChatMessage{{Player: "Up", Message: message}}, chat.Messages)
+	}
+
+	down.send(t, model.Action{Type: model.ActionChat, Message: strings.Repeat("a", io.MaxMessageSize)})
+	modelErr := model.Error{}
+	down.read(t, model.MessageError, &modelErr)
+	assert.Equal(t, model.ErrBadRequest, modelErr.Code)
+	assert.False(t, down.scanner.Scan(), "the connection is closed")
+}
+
+func TestServer_LongMessages_WebSocket(t *testing.T) {
+	srv, _, _ := newServer(t, time.Minute)
+	wsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		assert.Nil(t, srv.ServeWebSocket(wsLn))
+	}()
+	client, _, err := websocket.DefaultDialer.Dial("ws://"+wsLn.Addr().String(), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// the longest chat message, in characters that take two bytes each
+	message := strings.Repeat("å", 200)
+	assert.Nil(t, client.WriteJSON(model.Action{Type: model.ActionChat, Message: message}))
+	_, msg, err := client.ReadMessage()
+	assert.Nil(t, err)
+	envelope, err := io.Decode(msg)
+	assert.Nil(t, err)
+	modelErr := model.Error{}
+	assert.Nil(t, json.Unmarshal(envelope.Payload, &modelErr))
+	assert.Equal(t, model.ErrNotInGame, modelErr.Code, "a message of the longest size is read")
+
+	assert.Nil(t, client.WriteJSON(model.Action{Type: model.ActionChat, Message: strings.Repeat("a", io.MaxMessageSize)}))
+	_, _, err = client.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), "the connection is closed: %v", err)
+}
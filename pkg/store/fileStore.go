@@ -0,0 +1,202 @@
+package store
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const logSuffix = ".log"
+
+// FileStore keeps one append-only log file per game in a directory on local disk.
+type FileStore struct {
+	dir string
+}
+
+// SavedGame is an unfinished game read back from the store.
+type SavedGame struct {
+	Id       model.GameID
+	Deck     []model.Card
+	Sessions []model.Session
+	Actions  []*model.Action
+	// Log appends to the existing log of the game
+	Log model.GameLog
+}
+
+// record is a single line in a log. Exactly one field is set.
+type record struct {
+	Game    model.GameID   `json:"game,omitempty"`
+	Deck    []model.Card   `json:"deck,omitempty"`
+	Session *model.Session `json:"session,omitempty"`
+	Action  *model.Action  `json:"action,omitempty"`
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Create starts a new log for a game. It fails with an error satisfying os.IsExist
+// if an unfinished game with the same id is already stored.
+func (s *FileStore) Create(id model.GameID) (model.GameLog, error) {
+	path := s.path(id)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l := &fileLog{file: file, path: path}
+	err = l.write(record{Game: id})
+	if err != nil {
+		_ = l.Close(true)
+		return nil, err
+	}
+	return l, nil
+}
+
+// Load reads all unfinished games in the store.
+func (s *FileStore) Load() ([]SavedGame, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored games: %w", err)
+	}
+	games := make([]SavedGame, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), logSuffix) {
+			continue
+		}
+		game, err := s.load(filepath.Join(s.dir, f.Name()))
+		if err != nil {
+			log.Warn("skipping stored game ", f.Name(), ": ", err)
+			continue
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+func (s *FileStore) load(path string) (SavedGame, error) {
+	game := SavedGame{}
+	file, err := os.Open(path)
+	if err != nil {
+		return game, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+	// the length of the log up to the last complete record
+	var size int64
+	for scanner.Scan() {
+		rec := record{}
+		err = json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			// the server may have stopped in the middle of writing the last record
+			log.Warn("ignoring unreadable record in ", path, ": ", err)
+			break
+		}
+		size += int64(len(scanner.Bytes())) + 1
+		switch {
+		case rec.Game != "":
+			game.Id = rec.Game
+		case rec.Deck != nil:
+			game.Deck = rec.Deck
+		case rec.Session != nil:
+			game.Sessions = append(game.Sessions, *rec.Session)
+		case rec.Action != nil:
+			game.Actions = append(game.Actions, rec.Action)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return game, err
+	}
+	if game.Id == "" || game.Deck == nil {
+		return game, fmt.Errorf("log is incomplete")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return game, err
+	}
+	// the last record may be complete, but miss its newline
+	missingNewline := size > info.Size()
+	if !missingNewline {
+		// new records must not be appended to an incomplete one
+		err = os.Truncate(path, size)
+		if err != nil {
+			return game, err
+		}
+	}
+	appendFile, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return game, err
+	}
+	if missingNewline {
+		_, err = appendFile.Write([]byte{'\n'})
+		if err != nil {
+			_ = appendFile.Close()
+			return game, err
+		}
+	}
+	game.Log = &fileLog{file: appendFile, path: path}
+	return game, nil
+}
+
+func (s *FileStore) path(id model.GameID) string {
+	// game ids are chosen by clients, and must not be able to escape the directory
+	return filepath.Join(s.dir, base64.RawURLEncoding.EncodeToString([]byte(id))+logSuffix)
+}
+
+type fileLog struct {
+	lock sync.Mutex
+	file *os.File
+	path string
+	// only the first close counts, so a game that is kept at shutdown is not discarded if it ends later
+	closed bool
+}
+
+func (l *fileLog) WriteDeck(deck []model.Card) error {
+	return l.write(record{Deck: deck})
+}
+
+func (l *fileLog) WriteSession(session model.Session) error {
+	return l.write(record{Session: &session})
+}
+
+func (l *fileLog) WriteAction(action *model.Action) error {
+	return l.write(record{Action: action})
+}
+
+func (l *fileLog) write(rec record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+func (l *fileLog) Close(ended bool) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	err := l.file.Close()
+	if ended {
+		return os.Remove(l.path)
+	}
+	return err
+}
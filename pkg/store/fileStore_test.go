@@ -0,0 +1,69 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/egoon/hanabi-server/pkg/model"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hanabi-store")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	s, err := NewFileStore(dir)
+	assert.Nil(t, err)
+
+	deck := []model.Card{{Color: "B", Value: "1"}, {Color: "R", Value: "5"}}
+	unfinished, err := s.Create("../unfinished")
+	assert.Nil(t, err)
+	assert.Nil(t, unfinished.WriteDeck(deck))
+	assert.Nil(t, unfinished.WriteSession(model.Session{Player: "Up", Token: "secret"}))
+	assert.Nil(t, unfinished.WriteAction(&model.Action{Type: model.ActionCreate, ActivePlayer: "Up"}))
+	assert.Nil(t, unfinished.WriteAction(&model.Action{Type: model.ActionStart, ActivePlayer: "Up"}))
+	assert.Nil(t, unfinished.Close(false))
+
+	_, err = s.Create("../unfinished")
+	assert.True(t, os.IsExist(err), "creating a stored game again must fail")
+
+	ended, err := s.Create("ended")
+	assert.Nil(t, err)
+	assert.Nil(t, ended.WriteDeck(deck))
+	assert.Nil(t, ended.Close(true))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files), "only the unfinished game should remain, inside the store directory")
+
+	// a record cut short when the server stopped
+	file, err := os.OpenFile(filepath.Join(dir, files[0].Name()), os.O_WRONLY|os.O_APPEND, 0600)
+	assert.Nil(t, err)
+	_, err = file.Write([]byte(`{"action":{"type":"cl`))
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	games, err := s.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(games))
+	game := games[0]
+	assert.Equal(t, model.GameID("../unfinished"), game.Id)
+	assert.Equal(t, deck, game.Deck)
+	assert.Equal(t, []model.Session{{Player: "Up", Token: "secret"}}, game.Sessions)
+	assert.Equal(t, []*model.Action{
+		{Type: model.ActionCreate, ActivePlayer: "Up"},
+		{Type: model.ActionStart, ActivePlayer: "Up"},
+	}, game.Actions)
+	assert.NotNil(t, game.Log)
+	assert.Nil(t, game.Log.WriteAction(&model.Action{Type: model.ActionDiscard, ActivePlayer: "Up", Card: []int{0}}))
+	assert.Nil(t, game.Log.Close(false))
+
+	games, err = s.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(games))
+	assert.Equal(t, 3, len(games[0].Actions), "actions appended after a restart must be readable")
+	assert.Nil(t, games[0].Log.Close(true))
+}